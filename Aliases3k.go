@@ -0,0 +1,150 @@
+package googleadmin3k
+
+import (
+	"context"
+	"errors"
+	admin "google.golang.org/api/admin/directory/v1"
+	"google.golang.org/api/iterator"
+	"sort"
+	"strings"
+)
+
+/*Address owner kinds*/
+const (
+	OwnerUser  = "USER"
+	OwnerGroup = "GROUP"
+)
+
+// AddressOwner is the user or group an address belongs to, as its primary email or as an alias.
+type AddressOwner struct {
+	Address      string
+	Kind         string
+	ID           string
+	PrimaryEmail string
+}
+
+func (receiver *AddressOwner) IsAlias() bool {
+	return !strings.EqualFold(receiver.Address, receiver.PrimaryEmail)
+}
+
+// ResolveAddress finds the user, or else the group, that owns address. An address owned by neither fails
+// with ErrNotFound.
+func (receiver *Directory3k) ResolveAddress(address string, ctx context.Context) (*AddressOwner, error) {
+	var user *admin.User
+	err := receiver.retry("ResolveAddress", ctx, func() (err error) {
+		user, err = receiver.Service.Users.Get(address).Fields("id,primaryEmail").Context(ctx).Do()
+		return err
+	})
+	if err == nil {
+		return &AddressOwner{Address: address, Kind: OwnerUser, ID: user.Id, PrimaryEmail: user.PrimaryEmail}, nil
+	}
+	if !errors.Is(err, ErrNotFound) {
+		receiver.logger().Println(err.Error())
+		return nil, err
+	}
+
+	var group *admin.Group
+	err = receiver.retry("ResolveAddress", ctx, func() (err error) {
+		group, err = receiver.Service.Groups.Get(address).Fields("id,email").Context(ctx).Do()
+		return err
+	})
+	if err != nil {
+		receiver.logger().Println(err.Error())
+		return nil, err
+	}
+	return &AddressOwner{Address: address, Kind: OwnerGroup, ID: group.Id, PrimaryEmail: group.Email}, nil
+}
+
+/*Alias conflict report*/
+const (
+	// AliasConflictDuplicate is an address claimed by more than one user or group.
+	AliasConflictDuplicate = "DUPLICATE"
+	// AliasConflictMigration is an address in the target domain that more than one user or group would claim
+	// once their addresses are moved there.
+	AliasConflictMigration = "MIGRATION"
+)
+
+type AliasConflict struct {
+	Reason  string
+	Address string
+	Owners  []*AddressOwner
+}
+
+type AliasReport struct {
+	TargetDomain string
+	Addresses    int
+	Conflicts    []*AliasConflict
+}
+
+// AliasConflictReport gathers the primary emails and aliases of every user and group of the customer. With a
+// targetDomain it also checks what each address would become with its domain replaced by targetDomain.
+// Aliases Google derives from domain aliases are not editable and are left out.
+func (receiver *Directory3k) AliasConflictReport(targetDomain string, ctx context.Context) (*AliasReport, error) {
+	var owners []*AddressOwner
+	users := receiver.CustomerUsersIterator("", ctx, UserFieldsAliases)
+	for {
+		user, err := users.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, address := range append([]string{user.PrimaryEmail}, user.Aliases...) {
+			owners = append(owners, &AddressOwner{Address: address, Kind: OwnerUser, ID: user.Id, PrimaryEmail: user.PrimaryEmail})
+		}
+	}
+	groups := receiver.CustomerGroupsIterator("", ctx, GroupFieldsAliases)
+	for {
+		group, err := groups.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, address := range append([]string{group.Email}, group.Aliases...) {
+			owners = append(owners, &AddressOwner{Address: address, Kind: OwnerGroup, ID: group.Id, PrimaryEmail: group.Email})
+		}
+	}
+
+	report := &AliasReport{TargetDomain: targetDomain, Addresses: len(owners)}
+	report.Conflicts = findAliasConflicts(AliasConflictDuplicate, owners, func(address string) string { return address })
+	if targetDomain != "" {
+		migrated := findAliasConflicts(AliasConflictMigration, owners, func(address string) string {
+			return address[:strings.LastIndex(address, "@")+1] + targetDomain
+		})
+		duplicates := make(map[string]bool)
+		for _, conflict := range report.Conflicts {
+			duplicates[conflict.Address] = true
+		}
+		for _, conflict := range migrated {
+			if !duplicates[conflict.Address] {
+				report.Conflicts = append(report.Conflicts, conflict)
+			}
+		}
+	}
+	receiver.logger().Printf("Alias report: %d addresses, %d conflicts\n", report.Addresses, len(report.Conflicts))
+	return report, nil
+}
+
+// findAliasConflicts groups owners by the address target maps them to and keeps the addresses that more than
+// one user or group would hold.
+func findAliasConflicts(reason string, owners []*AddressOwner, target func(address string) string) []*AliasConflict {
+	claims := make(map[string][]*AddressOwner)
+	for _, owner := range owners {
+		address := strings.ToLower(target(owner.Address))
+		claims[address] = append(claims[address], owner)
+	}
+	var conflicts []*AliasConflict
+	for address, claimants := range claims {
+		for _, claimant := range claimants[1:] {
+			if claimant.ID != claimants[0].ID {
+				conflicts = append(conflicts, &AliasConflict{Reason: reason, Address: address, Owners: claimants})
+				break
+			}
+		}
+	}
+	sort.Slice(conflicts, func(i, j int) bool { return conflicts[i].Address < conflicts[j].Address })
+	return conflicts
+}
@@ -0,0 +1,135 @@
+package googleadmin3k
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	admin "google.golang.org/api/admin/directory/v1"
+)
+
+/*Archived User workflows*/
+
+// ArchiveUser moves the user from their Google Workspace license to the matching Archived User license and
+// marks them archived. Each step is undone if a later one fails.
+func (receiver *Workspace3k) ArchiveUser(userKey string, ctx context.Context) (*LicenseChange, error) {
+	return receiver.setArchived("ArchiveUser", userKey, true, ctx)
+}
+
+// UnarchiveUser is the reverse of ArchiveUser, giving the user back the license their Archived User SKU stands for.
+func (receiver *Workspace3k) UnarchiveUser(userKey string, ctx context.Context) (*LicenseChange, error) {
+	return receiver.setArchived("UnarchiveUser", userKey, false, ctx)
+}
+
+// setArchived grants the new license before the archived flag changes and removes the old one after it, so
+// the user always holds the license Google requires for their state. When both SKUs share a product the
+// license is swapped in place instead.
+func (receiver *Workspace3k) setArchived(op, userKey string, archived bool, ctx context.Context) (*LicenseChange, error) {
+	directory, err := receiver.Directory(ctx)
+	if err != nil {
+		return nil, err
+	}
+	licensing3k, err := receiver.Licensing(ctx)
+	if err != nil {
+		return nil, err
+	}
+	user, err := directory.GetUser(userKey, ctx, "primaryEmail,archived")
+	if err != nil {
+		return nil, err
+	}
+	if user.Archived == archived {
+		err = invalidArgument(op, "(%s) already has archived set to %t", user.PrimaryEmail, archived)
+		receiver.logger().Println(err.Error())
+		return nil, err
+	}
+
+	change := &LicenseChange{Action: LicenseUpdate, UserID: user.PrimaryEmail}
+	for _, pair := range archivalPairs() {
+		from, to := pair[0], pair[1]
+		if !archived {
+			from, to = to, from
+		}
+		if _, err = licensing3k.Get(&from, user.PrimaryEmail, ctx, LicenseFieldsUser); err == nil {
+			change.Before, change.After = productRef(from), productRef(to)
+			break
+		}
+		if !errors.Is(err, ErrNotFound) {
+			return nil, err
+		}
+	}
+	if change.Before == nil {
+		err = &Error3k{Op: op, Kind: ErrNotFound, Err: fmt.Errorf("(%s) holds no license with an archival counterpart", user.PrimaryEmail)}
+		receiver.logger().Println(err.Error())
+		return nil, err
+	}
+	sameProduct := change.Before.ProductID == change.After.ProductID
+
+	// Step 1: grant the new license.
+	if sameProduct {
+		_, err = licensing3k.SwapSKU(change.UserID, change.Before, change.After, ctx)
+	} else {
+		_, err = licensing3k.Insert(change.After, change.UserID, ctx)
+	}
+	if err != nil {
+		change.Err = err
+		return change, err
+	}
+	undoGrant := func() error {
+		if sameProduct {
+			_, err := licensing3k.SwapSKU(change.UserID, change.After, change.Before, ctx)
+			return err
+		}
+		return licensing3k.Delete(change.After, change.UserID, ctx)
+	}
+
+	// Step 2: flip the archived flag.
+	if _, err = directory.PatchUser(change.UserID, archivedPatch(archived), ctx); err != nil {
+		change.Err = withRollback(err, undoGrant())
+		return change, change.Err
+	}
+
+	// Step 3: release the old license.
+	if !sameProduct {
+		if err = licensing3k.Delete(change.Before, change.UserID, ctx); err != nil {
+			_, rollbackErr := directory.PatchUser(change.UserID, archivedPatch(!archived), ctx)
+			if rollbackErr == nil {
+				rollbackErr = undoGrant()
+			}
+			change.Err = withRollback(err, rollbackErr)
+			return change, change.Err
+		}
+	}
+	receiver.logger().Printf("%s: (%s) moved from <%s> to <%s>\n", op, change.UserID, change.Before.SKUName, change.After.SKUName)
+	return change, nil
+}
+
+// archivalPairs pairs every Archived User SKU, second, with the SKU it archives, first.
+func archivalPairs() [][2]Product {
+	var pairs [][2]Product
+	for _, archivedProduct := range DefaultCatalog.Products() {
+		if archivedProduct.UnarchivalSKUID == "" {
+			continue
+		}
+		active, ok := DefaultCatalog.BySKUID(archivedProduct.UnarchivalSKUID)
+		if !ok || active.ProductID != archivedProduct.UnarchivalProductID {
+			active = Product{
+				ProductID: archivedProduct.UnarchivalProductID,
+				SKUID:     archivedProduct.UnarchivalSKUID,
+				SKUName:   archivedProduct.UnarchivalSKUID,
+			}
+		}
+		pairs = append(pairs, [2]Product{active, archivedProduct})
+	}
+	return pairs
+}
+
+func archivedPatch(archived bool) *admin.User {
+	return &admin.User{Archived: archived, ForceSendFields: []string{"Archived"}}
+}
+
+// withRollback keeps err as the cause while reporting a rollback that failed too.
+func withRollback(err, rollbackErr error) error {
+	if rollbackErr == nil {
+		return err
+	}
+	return fmt.Errorf("%w (rollback failed: %v)", err, rollbackErr)
+}
@@ -0,0 +1,246 @@
+package googleadmin3k
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	admin "google.golang.org/api/admin/directory/v1"
+	"google.golang.org/api/googleapi"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MaxBatchSize is the most calls the Admin SDK accepts in one batch request.
+const MaxBatchSize = 1000
+
+// batchCall is one request packed into a multipart/mixed batch. Path is relative to the service base path.
+type batchCall struct {
+	Key    string
+	Method string
+	Path   string
+	Body   interface{}
+}
+
+/*Batched Directory methods*/
+func (receiver *Directory3k) BatchInsertMembers(groupEmail string, members []*admin.Member, ctx context.Context) ([]*BulkResult, error) {
+	calls := make([]*batchCall, len(members))
+	for i, member := range members {
+		calls[i] = &batchCall{
+			Key:    memberKey(member),
+			Method: http.MethodPost,
+			Path:   "admin/directory/v1/groups/" + url.PathEscape(groupEmail) + "/members",
+			Body:   member,
+		}
+	}
+	return receiver.runBatch("BatchInsertMembers", calls, ErrDuplicate, ctx)
+}
+
+func (receiver *Directory3k) BatchDeleteMembers(groupEmail string, memberKeys []string, ctx context.Context) ([]*BulkResult, error) {
+	if err := receiver.requireGroup(groupEmail, ctx); err != nil {
+		return nil, err
+	}
+	calls := make([]*batchCall, len(memberKeys))
+	for i, key := range memberKeys {
+		calls[i] = &batchCall{
+			Key:    key,
+			Method: http.MethodDelete,
+			Path:   "admin/directory/v1/groups/" + url.PathEscape(groupEmail) + "/members/" + url.PathEscape(key),
+		}
+	}
+	return receiver.runBatch("BatchDeleteMembers", calls, ErrNotFound, ctx)
+}
+
+// BatchUpdateUsers replaces each user, keyed by Id or else PrimaryEmail. Fields left empty are cleared.
+func (receiver *Directory3k) BatchUpdateUsers(users []*admin.User, ctx context.Context) ([]*BulkResult, error) {
+	return receiver.runBatch("BatchUpdateUsers", userBatchCalls(http.MethodPut, users), nil, ctx)
+}
+
+// BatchPatchUsers changes only the non-empty fields of each user, keyed by Id or else PrimaryEmail.
+func (receiver *Directory3k) BatchPatchUsers(users []*admin.User, ctx context.Context) ([]*BulkResult, error) {
+	return receiver.runBatch("BatchPatchUsers", userBatchCalls(http.MethodPatch, users), nil, ctx)
+}
+
+func userBatchCalls(method string, users []*admin.User) []*batchCall {
+	calls := make([]*batchCall, len(users))
+	for i, user := range users {
+		key := user.Id
+		if key == "" {
+			key = user.PrimaryEmail
+		}
+		calls[i] = &batchCall{Key: key, Method: method, Path: "admin/directory/v1/users/" + url.PathEscape(key), Body: user}
+	}
+	return calls
+}
+
+/*Batch transport*/
+
+// runBatch sends calls in batches of MaxBatchSize. Only the parts that fail with a retryable error are
+// sent again, following the receiver's RetryPolicy.
+func (receiver *Directory3k) runBatch(op string, calls []*batchCall, skipKind error, ctx context.Context) ([]*BulkResult, error) {
+	if receiver.Client == nil {
+		return nil, invalidArgument(op, "Directory3k has no http client, build it with BuildDirectory3k")
+	}
+	policy := receiver.RetryPolicy
+	if policy == nil {
+		policy = DefaultRetryPolicy
+	}
+	batchURL := strings.TrimSuffix(receiver.Service.BasePath, "/") + "/batch/admin/directory_v1"
+	partErrors := make([]error, len(calls))
+
+	for start := 0; start < len(calls); start += MaxBatchSize {
+		end := start + MaxBatchSize
+		if end > len(calls) {
+			end = len(calls)
+		}
+		pending := make([]int, 0, end-start)
+		for index := start; index < end; index++ {
+			pending = append(pending, index)
+		}
+
+		started := time.Now()
+		for attempt := 1; len(pending) > 0; attempt++ {
+			if ctx.Err() != nil {
+				for _, index := range pending {
+					partErrors[index] = wrapError(op, ctx.Err())
+				}
+				break
+			}
+			responses, err := receiver.sendBatch(batchURL, calls, pending, ctx)
+			var retryPending []int
+			var retryErr error
+			for _, index := range pending {
+				partErr := err
+				if partErr == nil {
+					partErr = responses[index]
+				}
+				partErrors[index] = wrapError(op, partErr)
+				if partErr != nil && IsRetryable(partErrors[index]) {
+					retryPending = append(retryPending, index)
+					retryErr = partErrors[index]
+				}
+			}
+			if len(retryPending) == 0 {
+				break
+			}
+			wait, ok := policy.Backoff(attempt, time.Since(started), retryErr)
+			if !ok {
+				break
+			}
+			receiver.logger().Printf("%s: %d of %d calls failed on attempt %d, retrying them in %v\n", op, len(retryPending), len(pending), attempt, wait.Round(time.Millisecond))
+			pending = retryPending
+			timer := time.NewTimer(wait)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+			case <-timer.C:
+			}
+		}
+		receiver.logger().Printf("%s: batch of calls %d to %d of %d done\n", op, start+1, end, len(calls))
+	}
+
+	results := make([]*BulkResult, len(calls))
+	for index, call := range calls {
+		result := &BulkResult{Key: call.Key, Status: BulkSucceeded, Err: partErrors[index]}
+		if result.Err != nil {
+			result.Status = BulkFailed
+			if skipKind != nil && errors.Is(result.Err, skipKind) {
+				result.Status = BulkSkipped
+			}
+		}
+		results[index] = result
+	}
+	receiver.logger().Printf("%s: %d succeeded, %d skipped, %d failed\n", op,
+		countBulkResults(results, BulkSucceeded), countBulkResults(results, BulkSkipped), countBulkResults(results, BulkFailed))
+	return results, firstBulkError(results)
+}
+
+// sendBatch posts the calls at indexes as one multipart/mixed request and returns the error of each part,
+// nil for parts that succeeded. A non-nil error means the batch request itself failed.
+func (receiver *Directory3k) sendBatch(batchURL string, calls []*batchCall, indexes []int, ctx context.Context) (map[int]error, error) {
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	for _, index := range indexes {
+		call := calls[index]
+		header := textproto.MIMEHeader{}
+		header.Set("Content-Type", "application/http")
+		header.Set("Content-ID", fmt.Sprintf("<item%d>", index))
+		part, err := writer.CreatePart(header)
+		if err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(part, "%s /%s HTTP/1.1\r\n", call.Method, call.Path)
+		if call.Body == nil {
+			fmt.Fprint(part, "\r\n")
+			continue
+		}
+		data, err := json.Marshal(call.Body)
+		if err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(part, "Content-Type: application/json\r\nContent-Length: %d\r\n\r\n", len(data))
+		part.Write(data)
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+
+	request, err := http.NewRequestWithContext(withRateLimitWeight(ctx, len(indexes)), http.MethodPost, batchURL, body)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Content-Type", "multipart/mixed; boundary="+writer.Boundary())
+	response, err := receiver.Client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	if err = googleapi.CheckResponse(response); err != nil {
+		return nil, err
+	}
+
+	mediaType, params, err := mime.ParseMediaType(response.Header.Get("Content-Type"))
+	if err != nil || !strings.HasPrefix(mediaType, "multipart/") {
+		return nil, fmt.Errorf("unexpected batch response content type %q", response.Header.Get("Content-Type"))
+	}
+	partErrors := make(map[int]error, len(indexes))
+	for _, index := range indexes {
+		partErrors[index] = errors.New("no response for batch part")
+	}
+	reader := multipart.NewReader(response.Body, params["boundary"])
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		contentID := part.Header.Get("Content-ID")
+		position := strings.LastIndex(contentID, "item")
+		if position < 0 {
+			continue
+		}
+		index, err := strconv.Atoi(strings.TrimSuffix(contentID[position+len("item"):], ">"))
+		if _, expected := partErrors[index]; err != nil || !expected {
+			continue
+		}
+		partResponse, err := http.ReadResponse(bufio.NewReader(part), nil)
+		if err != nil {
+			partErrors[index] = err
+			continue
+		}
+		partErrors[index] = googleapi.CheckResponse(partResponse)
+		partResponse.Body.Close()
+	}
+	return partErrors, nil
+}
@@ -0,0 +1,107 @@
+package googleadmin3k
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	admin "google.golang.org/api/admin/directory/v1"
+)
+
+func TestBatchInsertMembers(t *testing.T) {
+	mutex := &sync.Mutex{}
+	var rounds [][]string
+	directory := newTestDirectory(t, func(writer http.ResponseWriter, request *http.Request) {
+		if !strings.HasSuffix(request.URL.Path, "/batch/admin/directory_v1") {
+			writeAPIError(writer, 404, "notFound")
+			return
+		}
+		_, params, err := mime.ParseMediaType(request.Header.Get("Content-Type"))
+		if err != nil {
+			writeAPIError(writer, 400, "invalid")
+			return
+		}
+		mutex.Lock()
+		round := len(rounds)
+		rounds = append(rounds, nil)
+		mutex.Unlock()
+
+		reader := multipart.NewReader(request.Body, params["boundary"])
+		responseWriter := multipart.NewWriter(writer)
+		writer.Header().Set("Content-Type", "multipart/mixed; boundary="+responseWriter.Boundary())
+		for {
+			part, err := reader.NextPart()
+			if err != nil {
+				break
+			}
+			partRequest, err := http.ReadRequest(bufio.NewReader(part))
+			if err != nil {
+				t.Errorf("reading batch part: %v", err)
+				return
+			}
+			member := &admin.Member{}
+			json.NewDecoder(partRequest.Body).Decode(member)
+			mutex.Lock()
+			rounds[round] = append(rounds[round], member.Email)
+			mutex.Unlock()
+
+			header := textproto.MIMEHeader{}
+			header.Set("Content-Type", "application/http")
+			header.Set("Content-ID", "<response-"+strings.Trim(part.Header.Get("Content-ID"), "<>")+">")
+			responsePart, _ := responseWriter.CreatePart(header)
+			switch {
+			case strings.HasPrefix(member.Email, "dup"):
+				body := `{"error":{"code":409,"message":"Member already exists.","errors":[{"reason":"duplicate"}]}}`
+				fmt.Fprintf(responsePart, "HTTP/1.1 409 Conflict\r\nContent-Type: application/json\r\nContent-Length: %d\r\n\r\n%s", len(body), body)
+			case strings.HasPrefix(member.Email, "busy") && round == 0:
+				body := `{"error":{"code":503,"message":"Backend Error","errors":[{"reason":"backendError"}]}}`
+				fmt.Fprintf(responsePart, "HTTP/1.1 503 Service Unavailable\r\nContent-Type: application/json\r\nContent-Length: %d\r\n\r\n%s", len(body), body)
+			default:
+				body, _ := json.Marshal(member)
+				fmt.Fprintf(responsePart, "HTTP/1.1 200 OK\r\nContent-Type: application/json\r\nContent-Length: %d\r\n\r\n%s", len(body), body)
+			}
+		}
+		responseWriter.Close()
+	})
+	directory.RetryPolicy = &ExponentialBackoff{MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond, Multiplier: 1}
+
+	members := []*admin.Member{
+		{Email: "ok1@example.com"},
+		{Email: "dup@example.com"},
+		{Email: "busy@example.com"},
+		{Email: "ok2@example.com"},
+	}
+	results, err := directory.BatchInsertMembers("group@example.com", members, context.Background())
+	if err != nil {
+		t.Fatalf("BatchInsertMembers error = %v", err)
+	}
+
+	want := []string{BulkSucceeded, BulkSkipped, BulkSucceeded, BulkSucceeded}
+	for i, result := range results {
+		if result.Key != members[i].Email || result.Status != want[i] {
+			t.Errorf("result %d = %s %s, want %s %s", i, result.Key, result.Status, members[i].Email, want[i])
+		}
+	}
+	if !errors.Is(results[1].Err, ErrDuplicate) {
+		t.Errorf("duplicate error = %v, want ErrDuplicate", results[1].Err)
+	}
+	if len(rounds) != 2 {
+		t.Fatalf("got %d batch requests, want 2: %v", len(rounds), rounds)
+	}
+	if len(rounds[0]) != len(members) {
+		t.Errorf("first batch sent %v, want every member", rounds[0])
+	}
+	if len(rounds[1]) != 1 || rounds[1][0] != "busy@example.com" {
+		t.Errorf("retry batch sent %v, want only busy@example.com", rounds[1])
+	}
+}
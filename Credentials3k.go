@@ -0,0 +1,51 @@
+package googleadmin3k
+
+import (
+	"context"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+	"google.golang.org/api/impersonate"
+	"log"
+	"net/http"
+)
+
+/*Domain-wide delegation clients*/
+
+// ServiceAccountClient impersonates subject using a service account JSON key with domain-wide delegation.
+func ServiceAccountClient(serviceAccountKey []byte, subject string, scopes []string) (*http.Client, error) {
+	config, err := google.JWTConfigFromJSON(serviceAccountKey, scopes...)
+	if err != nil {
+		log.Println(err.Error())
+		return nil, wrapError("ServiceAccountClient", err)
+	}
+	config.Subject = subject
+	return config.Client(context.Background()), nil
+}
+
+// DefaultCredentialsClient impersonates subject using Application Default Credentials.
+// When serviceAccountEmail is empty the ADC must be a service account key with domain-wide delegation;
+// otherwise the ADC (workload identity, metadata server, gcloud user) signs on behalf of serviceAccountEmail,
+// which needs domain-wide delegation and must grant the caller roles/iam.serviceAccountTokenCreator.
+func DefaultCredentialsClient(serviceAccountEmail, subject string, scopes []string, ctx context.Context) (*http.Client, error) {
+	var tokenSource oauth2.TokenSource
+	if serviceAccountEmail == "" {
+		credentials, err := google.FindDefaultCredentialsWithParams(ctx, google.CredentialsParams{Scopes: scopes, Subject: subject})
+		if err != nil {
+			log.Println(err.Error())
+			return nil, wrapError("DefaultCredentialsClient", err)
+		}
+		tokenSource = credentials.TokenSource
+	} else {
+		impersonated, err := impersonate.CredentialsTokenSource(ctx, impersonate.CredentialsConfig{
+			TargetPrincipal: serviceAccountEmail,
+			Subject:         subject,
+			Scopes:          scopes,
+		})
+		if err != nil {
+			log.Println(err.Error())
+			return nil, wrapError("DefaultCredentialsClient", err)
+		}
+		tokenSource = impersonated
+	}
+	return oauth2.NewClient(context.Background(), tokenSource), nil
+}
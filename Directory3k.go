@@ -3,286 +3,365 @@ package googleadmin3k
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/google"
 	admin "google.golang.org/api/admin/directory/v1"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/iterator"
 	"google.golang.org/api/option"
 	"log"
 	"net/http"
 	"strings"
 	"sync"
-	"time"
 )
 
 /*Initializer*/
 type Directory3k struct {
 	Service    *admin.Service
+	Client     *http.Client
 	CustomerID string
 	AdminEmail string
 	Domain     string
+	Options3k
 }
 
 var tries = 0
 
-func BuildDirectory3k(client *http.Client, adminEmail string, ctx context.Context) *Directory3k {
-	newDirectoryAPI := &Directory3k{}
-	service, err := admin.NewService(ctx, option.WithHTTPClient(client))
+func BuildDirectory3k(client *http.Client, adminEmail string, ctx context.Context) (*Directory3k, error) {
+	return buildDirectory3k(client, adminEmail, "", Options3k{}, ctx)
+}
+
+func buildDirectory3k(client *http.Client, adminEmail, customerID string, options Options3k, ctx context.Context) (*Directory3k, error) {
+	newDirectoryAPI := &Directory3k{Options3k: options}
+	domain, err := domainFromEmail("BuildDirectory3k", adminEmail)
 	if err != nil {
-		log.Println(err.Error())
-		panic(err)
+		newDirectoryAPI.logger().Println(err.Error())
+		return nil, err
 	}
-	response, err := service.Users.Get(adminEmail).Fields("customerId").Do()
+	service, err := admin.NewService(ctx, option.WithHTTPClient(client))
 	if err != nil {
-		log.Println(err.Error())
-		panic(err)
+		newDirectoryAPI.logger().Println(err.Error())
+		return nil, wrapError("BuildDirectory3k", err)
+	}
+	if customerID == "" {
+		var response *admin.User
+		err = newDirectoryAPI.retry("BuildDirectory3k", ctx, func() (err error) {
+			response, err = service.Users.Get(adminEmail).Fields("customerId").Context(ctx).Do()
+			return err
+		})
+		if err != nil {
+			newDirectoryAPI.logger().Println(err.Error())
+			return nil, err
+		}
+		customerID = response.CustomerId
 	}
 	newDirectoryAPI.Service = service
-	newDirectoryAPI.CustomerID = response.CustomerId
+	newDirectoryAPI.Client = client
+	newDirectoryAPI.CustomerID = customerID
 	newDirectoryAPI.AdminEmail = adminEmail
-	newDirectoryAPI.Domain = strings.Split(adminEmail, "@")[1]
-	log.Printf("Directory3k -->Service: %v,\tCustomerID: %s,\tAdminEmail: %s,\tDomain: %s\n",
+	newDirectoryAPI.Domain = domain
+	newDirectoryAPI.logger().Printf("Directory3k -->Service: %v,\tCustomerID: %s,\tAdminEmail: %s,\tDomain: %s\n",
 		&newDirectoryAPI.Service, newDirectoryAPI.CustomerID, newDirectoryAPI.AdminEmail, newDirectoryAPI.Domain)
-	return newDirectoryAPI
+	return newDirectoryAPI, nil
 }
 
-func BuildDirectory3kOauth2(adminEmail string, scopes []string, clientSecret, authorizationToken []byte, ctx context.Context) *Directory3k {
+func BuildDirectory3kOauth2(adminEmail string, scopes []string, clientSecret, authorizationToken []byte, ctx context.Context) (*Directory3k, error) {
 	config, err := google.ConfigFromJSON(clientSecret, scopes...)
 	if err != nil {
 		log.Println(err.Error())
-		panic(err)
+		return nil, wrapError("BuildDirectory3kOauth2", err)
 	}
 	token := &oauth2.Token{}
 	err = json.Unmarshal(authorizationToken, token)
 	if err != nil {
 		log.Println(err.Error())
-		panic(err)
+		return nil, wrapError("BuildDirectory3kOauth2", err)
 	}
 	client := config.Client(context.Background(), token)
 	return BuildDirectory3k(client, adminEmail, ctx)
 }
 
+func BuildDirectory3kTokenStore(adminEmail string, scopes []string, clientSecret []byte, store TokenStore, ctx context.Context) (*Directory3k, error) {
+	client, err := TokenStoreClient(clientSecret, scopes, store)
+	if err != nil {
+		return nil, err
+	}
+	return BuildDirectory3k(client, adminEmail, ctx)
+}
+
+func BuildDirectory3kServiceAccount(adminEmail string, scopes []string, serviceAccountKey []byte, ctx context.Context) (*Directory3k, error) {
+	client, err := ServiceAccountClient(serviceAccountKey, adminEmail, scopes)
+	if err != nil {
+		return nil, err
+	}
+	return BuildDirectory3k(client, adminEmail, ctx)
+}
+
+func BuildDirectory3kDefaultCredentials(adminEmail, serviceAccountEmail string, scopes []string, ctx context.Context) (*Directory3k, error) {
+	client, err := DefaultCredentialsClient(serviceAccountEmail, adminEmail, scopes, ctx)
+	if err != nil {
+		return nil, err
+	}
+	return BuildDirectory3k(client, adminEmail, ctx)
+}
+
 /*Users methods*/
-func (receiver *Directory3k) QueryUsers(query string) []*admin.User {
-	request := receiver.Service.Users.List().Fields("*").Domain(receiver.Domain).Query(query).MaxResults(500)
+func (receiver *Directory3k) QueryUsers(query string, ctx context.Context, fields ...googleapi.Field) ([]*admin.User, error) {
+	users := receiver.UsersIterator(query, ctx, fields...)
 	var userList []*admin.User
 	for {
-		response, err := request.Do()
-		if err != nil {
-			log.Println(err.Error())
-			panic(err)
-		}
-		userList = append(userList, response.Users...)
-		log.Printf("Query \"%s\" returned %d users thus far.\n", query, len(userList))
-		if response.NextPageToken == "" {
+		page, err := users.NextPage()
+		if err == iterator.Done {
 			break
 		}
-		request.PageToken(response.NextPageToken)
+		if err != nil {
+			return userList, err
+		}
+		userList = append(userList, page...)
+		receiver.logger().Printf("Query \"%s\" returned %d users thus far.\n", query, len(userList))
 	}
 
-	return userList
+	return userList, nil
 }
 
-func (receiver *Directory3k) GetGroupsByUser(userEmail string) map[*admin.Group]*admin.Member {
-	groupList := receiver.GetGroups("memberKey=" + userEmail)
-	groupMap := make(map[*admin.Group]*admin.Member)
-	for counter, group := range groupList {
-		memberResponse, err := receiver.Service.Members.Get(group.Email, userEmail).Fields("*").Do()
-		if err != nil {
-			log.Println(err.Error())
-			panic(err)
+type GroupMembership struct {
+	Group *admin.Group
+	// Member is the user's membership of Group, or for an inherited group the membership of the nested
+	// group it comes through.
+	Member *admin.Member
+	// Path is the chain of groups from the one the user belongs to directly up to Group.
+	Path []string
+}
+
+type GroupsByUserOptions struct {
+	// IncludeInherited adds every group that contains one of the user's groups, at any depth.
+	IncludeInherited bool
+	// MaxRoutines bounds the concurrent lookups, DefaultGroupsByUserRoutines when zero.
+	MaxRoutines int
+}
+
+const DefaultGroupsByUserRoutines = 10
+
+// GetGroupsByUser lists the groups of userEmail with the member record of each, looked up concurrently.
+// Groups whose member record could not be fetched are still returned with a nil Member, alongside the
+// first error.
+func (receiver *Directory3k) GetGroupsByUser(userEmail string, options *GroupsByUserOptions, ctx context.Context) ([]*GroupMembership, error) {
+	maxRoutines := DefaultGroupsByUserRoutines
+	if options == nil {
+		options = &GroupsByUserOptions{}
+	}
+	if options.MaxRoutines > 0 {
+		maxRoutines = options.MaxRoutines
+	}
+	groupList, err := receiver.GetGroups("memberKey="+userEmail, ctx)
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool)
+	level := make([]*GroupMembership, 0, len(groupList))
+	for _, group := range groupList {
+		seen[strings.ToLower(group.Email)] = true
+		level = append(level, &GroupMembership{Group: group, Path: []string{group.Email}})
+	}
+
+	var memberships []*GroupMembership
+	var firstErr error
+	key := func(membership *GroupMembership) string { return membership.Group.Email }
+	for len(level) > 0 {
+		results := runPool(level, key, maxRoutines, nil, ctx, func(membership *GroupMembership) error {
+			memberEmail := userEmail
+			if len(membership.Path) > 1 {
+				memberEmail = membership.Path[len(membership.Path)-2]
+			}
+			return receiver.retry("GetGroupsByUser", ctx, func() (err error) {
+				membership.Member, err = receiver.Service.Members.Get(membership.Group.Email, memberEmail).Context(ctx).Do()
+				return err
+			})
+		})
+		if err := firstBulkError(results); err != nil && firstErr == nil {
+			receiver.logger().Println(err.Error())
+			firstErr = err
 		}
-		log.Printf("(%s) Group [%d] of [%d] {%s}: %s <%s>\n", userEmail, counter, len(groupList), memberResponse.Role, group.Name, group.Email)
-		groupMap[group] = memberResponse
+		memberships = append(memberships, level...)
+		if !options.IncludeInherited {
+			break
+		}
+
+		mutex := &sync.Mutex{}
+		parentsOf := make(map[*GroupMembership][]*admin.Group)
+		results = runPool(level, key, maxRoutines, nil, ctx, func(membership *GroupMembership) error {
+			parents, err := receiver.GetGroups("memberKey="+membership.Group.Email, ctx)
+			mutex.Lock()
+			parentsOf[membership] = parents
+			mutex.Unlock()
+			return err
+		})
+		if err := firstBulkError(results); err != nil && firstErr == nil {
+			firstErr = err
+		}
+		var next []*GroupMembership
+		for _, membership := range level {
+			for _, parent := range parentsOf[membership] {
+				if seen[strings.ToLower(parent.Email)] {
+					continue
+				}
+				seen[strings.ToLower(parent.Email)] = true
+				path := append(append([]string{}, membership.Path...), parent.Email)
+				next = append(next, &GroupMembership{Group: parent, Path: path})
+			}
+		}
+		level = next
 	}
-	return groupMap
+	receiver.logger().Printf("(%s) is in %d groups\n", userEmail, len(memberships))
+	return memberships, firstErr
 }
 
 /*Groups methods*/
-func (receiver *Directory3k) GetGroups(query string) []*admin.Group {
-	request := receiver.Service.Groups.List().Domain(receiver.Domain).Fields("*")
-	if query != "" {
-		request.Query(query)
-	}
+func (receiver *Directory3k) GetGroups(query string, ctx context.Context, fields ...googleapi.Field) ([]*admin.Group, error) {
+	groups := receiver.GroupsIterator(query, ctx, fields...)
 	var groupList []*admin.Group
 	for {
-		response, err := request.Do()
-		if err != nil {
-			log.Println(err.Error())
-			panic(err)
-		}
-		groupList = append(groupList, response.Groups...)
-		log.Printf("Query \"%s\" returned %d groups thus far.\n", query, len(groupList))
-		if response.NextPageToken == "" {
+		page, err := groups.NextPage()
+		if err == iterator.Done {
 			break
 		}
-		request.PageToken(response.NextPageToken)
+		if err != nil {
+			return groupList, err
+		}
+		groupList = append(groupList, page...)
+		receiver.logger().Printf("Query \"%s\" returned %d groups thus far.\n", query, len(groupList))
 	}
-	return groupList
+	return groupList, nil
 }
 
-func (receiver *Directory3k) GetGroupByEmail(groupEmail string) *admin.Group {
-	response, err := receiver.Service.Groups.Get(groupEmail).Fields("*").Do()
+func (receiver *Directory3k) GetGroupByEmail(groupEmail string, ctx context.Context, fields ...googleapi.Field) (*admin.Group, error) {
+	var response *admin.Group
+	err := receiver.retry("GetGroupByEmail", ctx, func() (err error) {
+		response, err = receiver.Service.Groups.Get(groupEmail).Fields(itemFields(fields)).Context(ctx).Do()
+		return err
+	})
 	if err != nil {
-		log.Println(err.Error())
-		panic(err)
+		receiver.logger().Println(err.Error())
+		return nil, err
 	}
-	return response
+	return response, nil
+}
+
+// requireGroup fails when the group does not exist. Deletes skip members that answer 404, which a missing
+// group would answer for every one of them.
+func (receiver *Directory3k) requireGroup(groupEmail string, ctx context.Context) error {
+	_, err := receiver.GetGroupByEmail(groupEmail, ctx, "id")
+	return err
 }
 
 /*Group Members methods*/
-func (receiver *Directory3k) PushMemberByEmail(groupEmail, userEmail, role string) *admin.Member {
-	return receiver.PushMember(groupEmail, &admin.Member{Email: userEmail, Role: role})
+func (receiver *Directory3k) PushMemberByEmail(groupEmail, userEmail, role string, ctx context.Context) (*admin.Member, error) {
+	return receiver.PushMember(groupEmail, &admin.Member{Email: userEmail, Role: role}, ctx)
 }
 
-func (receiver *Directory3k) PushMember(groupEmail string, member *admin.Member) *admin.Member {
-	request := receiver.Service.Members.Insert(groupEmail, member)
-	result, err := request.Do()
+func (receiver *Directory3k) PushMember(groupEmail string, member *admin.Member, ctx context.Context) (*admin.Member, error) {
+	var result *admin.Member
+	err := receiver.retry("PushMember", ctx, func() (err error) {
+		result, err = receiver.Service.Members.Insert(groupEmail, member).Context(ctx).Do()
+		return err
+	})
 	if err != nil {
-		if strings.Contains(err.Error(), "duplicate") {
-			log.Println(err.Error() + " - Skipping")
-			return nil
+		if errors.Is(err, ErrDuplicate) {
+			receiver.logger().Println(err.Error() + " - Skipping")
+			return nil, err
 		}
-		log.Println(err)
-		log.Printf("Insertion of [%s (%s-%s)] to group (%s) failed... Retrying in 2 seconds.", member.Email, member.Role, member.Type, groupEmail)
-		time.Sleep(2 * time.Second)
-		receiver.PushMember(groupEmail, member)
-		return nil
+		receiver.logger().Println(err)
+		receiver.logger().Printf("Insertion of [%s (%s-%s)] to group (%s) failed.", member.Email, member.Role, member.Type, groupEmail)
+		return nil, err
 	}
-	log.Printf("Insertion of [%s](%s) to (%s) was successful!", member.Email, member.Role, groupEmail)
-	return result
+	receiver.logger().Printf("Insertion of [%s](%s) to (%s) was successful!", member.Email, member.Role, groupEmail)
+	return result, nil
 }
 
-func (receiver *Directory3k) InsertMembers(memberList []*admin.Member, groupEmail string, maxRoutines int) []*admin.Member {
-	totalInserts := len(memberList)
-	var completedInserts []*admin.Member
-	log.Printf("Total members to insert into from %s: %d\n", groupEmail, totalInserts)
-
-	for {
-		if len(memberList) <= maxRoutines {
-			maxRoutines = len(memberList)
-		}
-		wg := &sync.WaitGroup{}
-		wg.Add(maxRoutines)
-		for i := range memberList[:maxRoutines] {
-			log.Printf("PushMemberByEmail user  [%d] of [%d]\n", len(completedInserts), totalInserts)
-			memberToInsert := memberList[i]
-			go func() {
-				receiver.PushMember(groupEmail, memberToInsert)
-				completedInserts = append(completedInserts, memberToInsert)
-				wg.Done()
-			}()
-			//go receiver.PushMemberWorker(groupEmail, memberToInsert, wg)
-		}
-		wg.Wait()
-
-		memberList = memberList[maxRoutines:]
-		if len(memberList) == 0 {
-			break
-		}
-	}
-	log.Printf("Total members inserted into %s: %d\n", groupEmail, len(completedInserts))
-	return completedInserts
+func (receiver *Directory3k) InsertMembers(memberList []*admin.Member, groupEmail string, maxRoutines int, ctx context.Context) ([]*BulkResult, error) {
+	receiver.logger().Printf("Total members to insert into from %s: %d\n", groupEmail, len(memberList))
+	results := runPool(memberList, memberKey, maxRoutines, ErrDuplicate, ctx, func(member *admin.Member) error {
+		_, err := receiver.PushMember(groupEmail, member, ctx)
+		return err
+	})
+	receiver.logger().Printf("Total members inserted into %s: %d, skipped: %d, failed: %d\n", groupEmail,
+		countBulkResults(results, BulkSucceeded), countBulkResults(results, BulkSkipped), countBulkResults(results, BulkFailed))
+	return results, firstBulkError(results)
 }
 
-func (receiver *Directory3k) DeleteMember(groupEmail, memberEmail string) {
-	request := receiver.Service.Members.Delete(groupEmail, memberEmail)
-	err := request.Do()
+func (receiver *Directory3k) DeleteMember(groupEmail, memberEmail string, ctx context.Context) error {
+	err := receiver.retry("DeleteMember", ctx, func() error {
+		return receiver.Service.Members.Delete(groupEmail, memberEmail).Context(ctx).Do()
+	})
 	if err != nil {
-		log.Println(err)
-		log.Printf("Deletion of [%s] from group (%s) failed... Retrying in 2 seconds", memberEmail, groupEmail)
-		time.Sleep(2 * time.Second)
-		receiver.DeleteMember(groupEmail, memberEmail)
-		return
+		receiver.logger().Println(err)
+		receiver.logger().Printf("Deletion of [%s] from group (%s) failed.", memberEmail, groupEmail)
+		return err
 	}
-	log.Printf("Deletetion of [%s] from (%s) was successful!", memberEmail, groupEmail)
+	receiver.logger().Printf("Deletetion of [%s] from (%s) was successful!", memberEmail, groupEmail)
+	return nil
 }
 
-func (receiver *Directory3k) DeleteMembers(deleteList []string, groupEmail string, batchSize int) {
-	totalDeletes := len(deleteList)
-	deleteCounter := 1
-	log.Printf("Total members to remove from %s: %d\n", groupEmail, totalDeletes)
-
-	for {
-		if len(deleteList) <= batchSize {
-			batchSize = len(deleteList)
-		}
-		wg := &sync.WaitGroup{}
-		wg.Add(batchSize)
-		for i := range deleteList[:batchSize] {
-			log.Printf("Delete user  [%d] of [%d]\n", deleteCounter, totalDeletes)
-			deleteCounter++
-			memberToDelete := deleteList[i]
-			go func() {
-				receiver.DeleteMember(groupEmail, memberToDelete)
-				wg.Done()
-			}()
-		}
-		wg.Wait()
-
-		deleteList = deleteList[batchSize:]
-		if len(deleteList) == 0 {
-			break
-		}
+func (receiver *Directory3k) DeleteMembers(deleteList []string, groupEmail string, maxRoutines int, ctx context.Context) ([]*BulkResult, error) {
+	receiver.logger().Printf("Total members to remove from %s: %d\n", groupEmail, len(deleteList))
+	if err := receiver.requireGroup(groupEmail, ctx); err != nil {
+		return nil, err
 	}
+	results := runPool(deleteList, func(memberEmail string) string { return memberEmail }, maxRoutines, ErrNotFound, ctx, func(memberEmail string) error {
+		return receiver.DeleteMember(groupEmail, memberEmail, ctx)
+	})
+	receiver.logger().Printf("Total members removed from %s: %d, skipped: %d, failed: %d\n", groupEmail,
+		countBulkResults(results, BulkSucceeded), countBulkResults(results, BulkSkipped), countBulkResults(results, BulkFailed))
+	return results, firstBulkError(results)
+}
 
-	log.Printf("Total members removed from %s: %d\n", groupEmail, deleteCounter)
+func (receiver *Directory3k) GetGroupMembersByRole(groupEmail string, roles []string, ctx context.Context, fields ...googleapi.Field) ([]*admin.Member, error) {
+	receiver.logger().Printf("Retreiving  %s members from %s\n", strings.ToUpper(strings.Join(roles, ",")), groupEmail)
+	return receiver.ListMembers(groupEmail, &MemberFilter{Roles: roles}, ctx, fields...)
 }
 
-func (receiver *Directory3k) GetGroupMembersByRole(groupEmail string, roles []string) []*admin.Member {
-	allRoles := strings.ToUpper(strings.Join(roles, ","))
-	log.Printf("Retreiving  %s members from %s\n", allRoles, groupEmail)
+func (receiver *Directory3k) ListMembers(groupEmail string, filter *MemberFilter, ctx context.Context, fields ...googleapi.Field) ([]*admin.Member, error) {
+	memberPages := receiver.FilteredMembersIterator(groupEmail, filter, ctx, fields...)
 	var members []*admin.Member
 	for {
-		request, err := receiver.Service.Members.List(groupEmail).Roles(allRoles).Fields("*").MaxResults(200).Do()
-		if err != nil {
-			log.Println(err.Error())
-			if strings.Contains(err.Error(), "Quota") {
-				log.Println("Backing off for 3 seconds...")
-				time.Sleep(time.Second * 3)
-				return receiver.GetGroupMembersByRole(groupEmail, roles)
-			}
-			return nil
-		}
-		members = append(members, request.Members...)
-		nextPageToken := request.NextPageToken
-		if nextPageToken == "" {
-			log.Printf("%s has %d members\n", groupEmail, len(members))
+		page, err := memberPages.NextPage()
+		if err == iterator.Done {
+			receiver.logger().Printf("%s has %d matching members\n", groupEmail, len(members))
 			break
 		}
-		log.Printf("Members thus far %s --> [%d]\n", groupEmail, len(members))
+		if err != nil {
+			return members, err
+		}
+		members = append(members, page...)
+		receiver.logger().Printf("Members thus far %s --> [%d]\n", groupEmail, len(members))
 	}
-	return members
+	return members, nil
 }
 
-func (receiver *Directory3k) GetAllMembers(groupEmail string) []*admin.Member {
+func (receiver *Directory3k) GetAllMembers(groupEmail string, ctx context.Context, fields ...googleapi.Field) ([]*admin.Member, error) {
+	memberPages := receiver.MembersIterator(groupEmail, ctx, fields...)
 	var members []*admin.Member
-	nextPageToken := ""
 	for {
-		request, err := receiver.Service.Members.List(groupEmail).Fields("*").PageToken(nextPageToken).MaxResults(200).Do()
-		if err != nil {
-			log.Println(err.Error())
-			if strings.Contains(err.Error(), "Quota") {
-				log.Println("Backing off for 2 seconds...")
-				time.Sleep(time.Second * 2)
-				return receiver.GetAllMembers(groupEmail)
-			}
-			return nil
-		}
-		members = append(members, request.Members...)
-		nextPageToken = request.NextPageToken
-		if nextPageToken == "" {
-			log.Printf("%s has %d members\n", groupEmail, len(members))
+		page, err := memberPages.NextPage()
+		if err == iterator.Done {
+			receiver.logger().Printf("%s has %d members\n", groupEmail, len(members))
 			break
 		}
-		log.Printf("Members thus far %s --> [%d]\n", groupEmail, len(members))
+		if err != nil {
+			return members, err
+		}
+		members = append(members, page...)
+		receiver.logger().Printf("Members thus far %s --> [%d]\n", groupEmail, len(members))
 	}
-	return members
+	return members, nil
 }
 
-func (receiver *Directory3k) GetAllMembersEmails(groupEmail string) []string {
+func (receiver *Directory3k) GetAllMembersEmails(groupEmail string, ctx context.Context) ([]string, error) {
+	members, err := receiver.GetAllMembers(groupEmail, ctx, MemberFieldsEmail)
 	var emails []string
-	for _, member := range receiver.GetAllMembers(groupEmail) {
+	for _, member := range members {
 		emails = append(emails, member.Email)
 	}
-	return emails
+	return emails, err
 }
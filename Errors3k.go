@@ -0,0 +1,114 @@
+package googleadmin3k
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"google.golang.org/api/googleapi"
+	"net"
+	"strings"
+)
+
+/*Error kinds, match with errors.Is*/
+var (
+	ErrNotFound         = errors.New("not found")
+	ErrPermissionDenied = errors.New("permission denied")
+	ErrQuotaExceeded    = errors.New("quota exceeded")
+	ErrTransient        = errors.New("transient failure")
+	ErrDuplicate        = errors.New("duplicate")
+	ErrInvalidArgument  = errors.New("invalid argument")
+)
+
+// Error3k wraps every error returned by this package. Err is usually a *googleapi.Error.
+type Error3k struct {
+	Op   string
+	Kind error
+	Err  error
+}
+
+func (receiver *Error3k) Error() string {
+	if receiver.Kind == nil {
+		return fmt.Sprintf("%s: %v", receiver.Op, receiver.Err)
+	}
+	return fmt.Sprintf("%s: %v: %v", receiver.Op, receiver.Kind, receiver.Err)
+}
+
+func (receiver *Error3k) Unwrap() error {
+	return receiver.Err
+}
+
+func (receiver *Error3k) Is(target error) bool {
+	return receiver.Kind != nil && receiver.Kind == target
+}
+
+// StatusCode returns the HTTP status of the underlying googleapi.Error, or 0.
+func (receiver *Error3k) StatusCode() int {
+	var apiErr *googleapi.Error
+	if errors.As(receiver.Err, &apiErr) {
+		return apiErr.Code
+	}
+	return 0
+}
+
+func wrapError(op string, err error) error {
+	if err == nil {
+		return nil
+	}
+	var e3k *Error3k
+	if errors.As(err, &e3k) {
+		return err
+	}
+	return &Error3k{Op: op, Kind: classifyError(err), Err: err}
+}
+
+func invalidArgument(op, format string, args ...interface{}) error {
+	return &Error3k{Op: op, Kind: ErrInvalidArgument, Err: fmt.Errorf(format, args...)}
+}
+
+func classifyError(err error) error {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return nil
+	}
+	var apiErr *googleapi.Error
+	if !errors.As(err, &apiErr) {
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			return ErrTransient
+		}
+		return nil
+	}
+	switch {
+	case apiErr.Code == 409 || hasReason(apiErr, "duplicate"):
+		return ErrDuplicate
+	case apiErr.Code == 404 || hasReason(apiErr, "notFound"):
+		return ErrNotFound
+	case apiErr.Code == 429 || hasReason(apiErr, "rateLimitExceeded", "userRateLimitExceeded", "quotaExceeded", "dailyLimitExceeded"):
+		return ErrQuotaExceeded
+	case apiErr.Code == 401 || apiErr.Code == 403:
+		return ErrPermissionDenied
+	case apiErr.Code == 400:
+		return ErrInvalidArgument
+	case apiErr.Code >= 500:
+		return ErrTransient
+	}
+	return nil
+}
+
+func hasReason(apiErr *googleapi.Error, reasons ...string) bool {
+	for _, item := range apiErr.Errors {
+		for _, reason := range reasons {
+			if strings.EqualFold(item.Reason, reason) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func domainFromEmail(op, email string) (string, error) {
+	parts := strings.Split(email, "@")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", invalidArgument(op, "invalid email address %q", email)
+	}
+	return parts[1], nil
+}
@@ -0,0 +1,40 @@
+package googleadmin3k
+
+import (
+	"google.golang.org/api/googleapi"
+)
+
+/*Common field selections, combine them or pass raw googleapi.Field paths to any read method*/
+const (
+	UserFieldsEmail   googleapi.Field = "primaryEmail"
+	UserFieldsOrgUnit googleapi.Field = "primaryEmail,orgUnitPath"
+	UserFieldsBasic   googleapi.Field = "id,primaryEmail,name,orgUnitPath,suspended,archived"
+	UserFieldsAdmin   googleapi.Field = "id,primaryEmail,isAdmin,isDelegatedAdmin"
+	UserFieldsAliases googleapi.Field = "id,primaryEmail,aliases,nonEditableAliases"
+
+	GroupFieldsEmail   googleapi.Field = "email"
+	GroupFieldsBasic   googleapi.Field = "id,email,name,description,directMembersCount"
+	GroupFieldsAliases googleapi.Field = "id,email,aliases,nonEditableAliases"
+
+	MemberFieldsEmail googleapi.Field = "email"
+	MemberFieldsBasic googleapi.Field = "id,email,role,type,status,delivery_settings"
+
+	LicenseFieldsUser  googleapi.Field = "userId"
+	LicenseFieldsBasic googleapi.Field = "userId,productId,skuId,skuName"
+)
+
+// itemFields is the selection for a single resource, "*" when none are given.
+func itemFields(fields []googleapi.Field) googleapi.Field {
+	if len(fields) == 0 {
+		return "*"
+	}
+	return googleapi.Field(googleapi.CombineFields(fields))
+}
+
+// listFields projects fields onto the items of a list response, "*" when none are given.
+func listFields(collection string, fields []googleapi.Field) googleapi.Field {
+	if len(fields) == 0 {
+		return "*"
+	}
+	return googleapi.Field("nextPageToken," + collection + "(" + googleapi.CombineFields(fields) + ")")
+}
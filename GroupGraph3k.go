@@ -0,0 +1,197 @@
+package googleadmin3k
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// GroupGraph is the nesting structure below a set of root groups, each group fetched once.
+type GroupGraph struct {
+	Roots  []string              `json:"roots"`
+	Groups map[string]*GroupNode `json:"groups"`
+}
+
+// GroupNode holds the direct members of a group. Recipients are USER and EXTERNAL members; Customer is
+// set when the whole organization is a member. Error is set for nested groups that could not be listed,
+// such as groups owned by another organization.
+type GroupNode struct {
+	Email      string   `json:"email"`
+	Recipients []string `json:"recipients,omitempty"`
+	Groups     []string `json:"groups,omitempty"`
+	Customer   bool     `json:"customer,omitempty"`
+	Error      string   `json:"error,omitempty"`
+}
+
+type EffectiveMember struct {
+	Email string
+	// Path is the shortest chain of groups from the expanded group to the one holding the member directly.
+	Path []string
+}
+
+// ExpandGroup resolves groupEmail into every address that effectively receives its mail.
+func (receiver *Directory3k) ExpandGroup(groupEmail string, ctx context.Context) ([]*EffectiveMember, error) {
+	graph, err := receiver.BuildGroupGraph([]string{groupEmail}, ctx)
+	if err != nil {
+		return nil, err
+	}
+	return graph.Expand(groupEmail), nil
+}
+
+func (receiver *Directory3k) BuildGroupGraph(rootEmails []string, ctx context.Context) (*GroupGraph, error) {
+	graph := &GroupGraph{Groups: make(map[string]*GroupNode)}
+	var queue []string
+	for _, root := range rootEmails {
+		root = strings.ToLower(root)
+		graph.Roots = append(graph.Roots, root)
+		queue = append(queue, root)
+	}
+
+	for len(queue) > 0 {
+		groupEmail := queue[0]
+		queue = queue[1:]
+		if _, seen := graph.Groups[groupEmail]; seen {
+			continue
+		}
+		node := &GroupNode{Email: groupEmail}
+		graph.Groups[groupEmail] = node
+
+		members, err := receiver.GetAllMembers(groupEmail, ctx, "email", "type")
+		if err != nil {
+			if errors.Is(err, ErrNotFound) || errors.Is(err, ErrPermissionDenied) {
+				node.Error = err.Error()
+				continue
+			}
+			return graph, err
+		}
+		for _, member := range members {
+			email := strings.ToLower(member.Email)
+			switch member.Type {
+			case "GROUP":
+				node.Groups = append(node.Groups, email)
+				queue = append(queue, email)
+			case "CUSTOMER":
+				node.Customer = true
+			default:
+				node.Recipients = append(node.Recipients, email)
+			}
+		}
+		receiver.logger().Printf("Group graph: %s has %d recipients and %d nested groups\n", groupEmail, len(node.Recipients), len(node.Groups))
+	}
+	return graph, nil
+}
+
+// Expand walks the groups nested under groupEmail breadth first, so every member gets its shortest path
+// and cycles are visited only once. Groups that contain the whole organization are flagged by Customer on
+// their GroupNode rather than expanded.
+func (receiver *GroupGraph) Expand(groupEmail string) []*EffectiveMember {
+	groupEmail = strings.ToLower(groupEmail)
+	var effective []*EffectiveMember
+	seenMembers := make(map[string]bool)
+	paths := map[string][]string{groupEmail: {groupEmail}}
+	queue := []string{groupEmail}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		node, ok := receiver.Groups[current]
+		if !ok {
+			continue
+		}
+		for _, email := range node.Recipients {
+			if !seenMembers[email] {
+				seenMembers[email] = true
+				effective = append(effective, &EffectiveMember{Email: email, Path: paths[current]})
+			}
+		}
+		for _, nested := range node.Groups {
+			if _, visited := paths[nested]; visited {
+				continue
+			}
+			paths[nested] = append(append([]string{}, paths[current]...), nested)
+			queue = append(queue, nested)
+		}
+	}
+	sort.Slice(effective, func(i, j int) bool { return effective[i].Email < effective[j].Email })
+	return effective
+}
+
+// Cycles lists each nesting loop once, as the chain of groups starting and ending with the same group.
+func (receiver *GroupGraph) Cycles() [][]string {
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := make(map[string]int)
+	var stack []string
+	var cycles [][]string
+	var visit func(string)
+	visit = func(groupEmail string) {
+		state[groupEmail] = visiting
+		stack = append(stack, groupEmail)
+		if node, ok := receiver.Groups[groupEmail]; ok {
+			for _, nested := range node.Groups {
+				switch state[nested] {
+				case unvisited:
+					visit(nested)
+				case visiting:
+					for i := len(stack) - 1; i >= 0; i-- {
+						if stack[i] == nested {
+							cycle := append(append([]string{}, stack[i:]...), nested)
+							cycles = append(cycles, cycle)
+							break
+						}
+					}
+				}
+			}
+		}
+		stack = stack[:len(stack)-1]
+		state[groupEmail] = done
+	}
+	for _, groupEmail := range receiver.sortedEmails() {
+		if state[groupEmail] == unvisited {
+			visit(groupEmail)
+		}
+	}
+	return cycles
+}
+
+/*Exports*/
+func (receiver *GroupGraph) JSON() ([]byte, error) {
+	return json.MarshalIndent(receiver, "", "  ")
+}
+
+func (receiver *GroupGraph) DOT() string {
+	builder := &strings.Builder{}
+	builder.WriteString("digraph groups {\n\trankdir=LR;\n\tnode [shape=box];\n")
+	for _, groupEmail := range receiver.sortedEmails() {
+		node := receiver.Groups[groupEmail]
+		label := fmt.Sprintf("%s\\n%d recipients", node.Email, len(node.Recipients))
+		if node.Customer {
+			label += "\\n+ entire organization"
+		}
+		attributes := ""
+		if node.Error != "" {
+			label += "\\nnot readable"
+			attributes = ", style=dashed"
+		}
+		fmt.Fprintf(builder, "\t%q [label=\"%s\"%s];\n", node.Email, label, attributes)
+		for _, nested := range node.Groups {
+			fmt.Fprintf(builder, "\t%q -> %q;\n", node.Email, nested)
+		}
+	}
+	builder.WriteString("}\n")
+	return builder.String()
+}
+
+func (receiver *GroupGraph) sortedEmails() []string {
+	emails := make([]string, 0, len(receiver.Groups))
+	for email := range receiver.Groups {
+		emails = append(emails, email)
+	}
+	sort.Strings(emails)
+	return emails
+}
@@ -0,0 +1,128 @@
+package googleadmin3k
+
+import (
+	"context"
+	"encoding/json"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/groupssettings/v1"
+	"google.golang.org/api/option"
+	"log"
+	"net/http"
+)
+
+/*Initializers*/
+type GroupSettings3k struct {
+	Service    *groupssettings.Service
+	AdminEmail string
+	Domain     string
+	Options3k
+}
+
+func BuildGroupSettings3k(client *http.Client, adminEmail string, ctx context.Context) (*GroupSettings3k, error) {
+	return buildGroupSettings3k(client, adminEmail, Options3k{}, ctx)
+}
+
+func buildGroupSettings3k(client *http.Client, adminEmail string, options Options3k, ctx context.Context) (*GroupSettings3k, error) {
+	groupSettings3k := &GroupSettings3k{Options3k: options}
+	domain, err := domainFromEmail("BuildGroupSettings3k", adminEmail)
+	if err != nil {
+		groupSettings3k.logger().Println(err.Error())
+		return nil, err
+	}
+	service, err := groupssettings.NewService(ctx, option.WithHTTPClient(client))
+	if err != nil {
+		groupSettings3k.logger().Println(err.Error())
+		return nil, wrapError("BuildGroupSettings3k", err)
+	}
+	groupSettings3k.Service = service
+	groupSettings3k.AdminEmail = adminEmail
+	groupSettings3k.Domain = domain
+	groupSettings3k.logger().Printf("GroupSettings3k -->Service: %v,\tAdminEmail: %s,\tDomain: %s\n",
+		&groupSettings3k.Service, groupSettings3k.AdminEmail, groupSettings3k.Domain)
+	return groupSettings3k, nil
+}
+
+func BuildGroupSettings3kOauth2(adminEmail string, scopes []string, clientSecret, authorizationToken []byte, ctx context.Context) (*GroupSettings3k, error) {
+	config, err := google.ConfigFromJSON(clientSecret, scopes...)
+	if err != nil {
+		log.Println(err.Error())
+		return nil, wrapError("BuildGroupSettings3kOauth2", err)
+	}
+	token := &oauth2.Token{}
+	err = json.Unmarshal(authorizationToken, token)
+	if err != nil {
+		log.Println(err.Error())
+		return nil, wrapError("BuildGroupSettings3kOauth2", err)
+	}
+	client := config.Client(context.Background(), token)
+	return BuildGroupSettings3k(client, adminEmail, ctx)
+}
+
+func BuildGroupSettings3kTokenStore(adminEmail string, scopes []string, clientSecret []byte, store TokenStore, ctx context.Context) (*GroupSettings3k, error) {
+	client, err := TokenStoreClient(clientSecret, scopes, store)
+	if err != nil {
+		return nil, err
+	}
+	return BuildGroupSettings3k(client, adminEmail, ctx)
+}
+
+func BuildGroupSettings3kServiceAccount(adminEmail string, scopes []string, serviceAccountKey []byte, ctx context.Context) (*GroupSettings3k, error) {
+	client, err := ServiceAccountClient(serviceAccountKey, adminEmail, scopes)
+	if err != nil {
+		return nil, err
+	}
+	return BuildGroupSettings3k(client, adminEmail, ctx)
+}
+
+func BuildGroupSettings3kDefaultCredentials(adminEmail, serviceAccountEmail string, scopes []string, ctx context.Context) (*GroupSettings3k, error) {
+	client, err := DefaultCredentialsClient(serviceAccountEmail, adminEmail, scopes, ctx)
+	if err != nil {
+		return nil, err
+	}
+	return BuildGroupSettings3k(client, adminEmail, ctx)
+}
+
+/*Methods*/
+func (receiver *GroupSettings3k) Get(groupEmail string, ctx context.Context, fields ...googleapi.Field) (*groupssettings.Groups, error) {
+	var response *groupssettings.Groups
+	err := receiver.retry("GroupSettings3k.Get", ctx, func() (err error) {
+		response, err = receiver.Service.Groups.Get(groupEmail).Fields(itemFields(fields)).Context(ctx).Do()
+		return err
+	})
+	if err != nil {
+		receiver.logger().Println(err.Error())
+		return nil, err
+	}
+	return response, nil
+}
+
+// Update replaces every setting, Patch changes only the non-empty fields of settings.
+func (receiver *GroupSettings3k) Update(groupEmail string, settings *groupssettings.Groups, ctx context.Context) (*groupssettings.Groups, error) {
+	var response *groupssettings.Groups
+	err := receiver.retry("GroupSettings3k.Update", ctx, func() (err error) {
+		response, err = receiver.Service.Groups.Update(groupEmail, settings).Context(ctx).Do()
+		return err
+	})
+	if err != nil {
+		receiver.logger().Println(err.Error())
+		return nil, err
+	}
+	receiver.logger().Printf("Settings of group [%s] updated\n", groupEmail)
+	return response, nil
+}
+
+func (receiver *GroupSettings3k) Patch(groupEmail string, settings *groupssettings.Groups, ctx context.Context) (*groupssettings.Groups, error) {
+	var response *groupssettings.Groups
+	err := receiver.retry("GroupSettings3k.Patch", ctx, func() (err error) {
+		response, err = receiver.Service.Groups.Patch(groupEmail, settings).Context(ctx).Do()
+		return err
+	})
+	if err != nil {
+		receiver.logger().Println(err.Error())
+		return nil, err
+	}
+	receiver.logger().Printf("Settings of group [%s] patched\n", groupEmail)
+	return response, nil
+}
@@ -0,0 +1,140 @@
+package googleadmin3k
+
+import (
+	"context"
+	"encoding/json"
+	admin "google.golang.org/api/admin/directory/v1"
+)
+
+/*Group lifecycle methods*/
+func (receiver *Directory3k) CreateGroup(group *admin.Group, ctx context.Context) (*admin.Group, error) {
+	if group == nil {
+		return nil, invalidArgument("CreateGroup", "group is nil")
+	}
+	if _, err := domainFromEmail("CreateGroup", group.Email); err != nil {
+		receiver.logger().Println(err.Error())
+		return nil, err
+	}
+	var response *admin.Group
+	err := receiver.retry("CreateGroup", ctx, func() (err error) {
+		response, err = receiver.Service.Groups.Insert(group).Context(ctx).Do()
+		return err
+	})
+	if err != nil {
+		receiver.logger().Println(err.Error())
+		return nil, err
+	}
+	receiver.logger().Printf("Group [%s] created\n", response.Email)
+	return response, nil
+}
+
+// UpdateGroup replaces the group resource, fields left empty are cleared. Use PatchGroup to change only some fields.
+func (receiver *Directory3k) UpdateGroup(groupKey string, group *admin.Group, ctx context.Context) (*admin.Group, error) {
+	var response *admin.Group
+	err := receiver.retry("UpdateGroup", ctx, func() (err error) {
+		response, err = receiver.Service.Groups.Update(groupKey, group).Context(ctx).Do()
+		return err
+	})
+	if err != nil {
+		receiver.logger().Println(err.Error())
+		return nil, err
+	}
+	receiver.logger().Printf("Group [%s] updated\n", groupKey)
+	return response, nil
+}
+
+func (receiver *Directory3k) PatchGroup(groupKey string, group *admin.Group, ctx context.Context) (*admin.Group, error) {
+	var response *admin.Group
+	err := receiver.retry("PatchGroup", ctx, func() (err error) {
+		response, err = receiver.Service.Groups.Patch(groupKey, group).Context(ctx).Do()
+		return err
+	})
+	if err != nil {
+		receiver.logger().Println(err.Error())
+		return nil, err
+	}
+	receiver.logger().Printf("Group [%s] patched\n", groupKey)
+	return response, nil
+}
+
+// RenameGroup changes the group email, Google keeps the old address as an alias.
+func (receiver *Directory3k) RenameGroup(groupKey, newEmail string, ctx context.Context) (*admin.Group, error) {
+	if _, err := domainFromEmail("RenameGroup", newEmail); err != nil {
+		receiver.logger().Println(err.Error())
+		return nil, err
+	}
+	return receiver.PatchGroup(groupKey, &admin.Group{Email: newEmail}, ctx)
+}
+
+func (receiver *Directory3k) DeleteGroup(groupKey string, ctx context.Context) error {
+	err := receiver.retry("DeleteGroup", ctx, func() error {
+		return receiver.Service.Groups.Delete(groupKey).Context(ctx).Do()
+	})
+	if err != nil {
+		receiver.logger().Println(err.Error())
+		return err
+	}
+	receiver.logger().Printf("Group [%s] deleted\n", groupKey)
+	return nil
+}
+
+/*Group aliases methods*/
+func (receiver *Directory3k) ListGroupAliases(groupKey string, ctx context.Context) ([]string, error) {
+	var response *admin.Aliases
+	err := receiver.retry("ListGroupAliases", ctx, func() (err error) {
+		response, err = receiver.Service.Groups.Aliases.List(groupKey).Context(ctx).Do()
+		return err
+	})
+	if err != nil {
+		receiver.logger().Println(err.Error())
+		return nil, err
+	}
+	return aliasNames("ListGroupAliases", response)
+}
+
+func (receiver *Directory3k) InsertGroupAlias(groupKey, alias string, ctx context.Context) (*admin.Alias, error) {
+	if _, err := domainFromEmail("InsertGroupAlias", alias); err != nil {
+		receiver.logger().Println(err.Error())
+		return nil, err
+	}
+	var response *admin.Alias
+	err := receiver.retry("InsertGroupAlias", ctx, func() (err error) {
+		response, err = receiver.Service.Groups.Aliases.Insert(groupKey, &admin.Alias{Alias: alias}).Context(ctx).Do()
+		return err
+	})
+	if err != nil {
+		receiver.logger().Println(err.Error())
+		return nil, err
+	}
+	receiver.logger().Printf("Alias [%s] added to group (%s)\n", alias, groupKey)
+	return response, nil
+}
+
+func (receiver *Directory3k) DeleteGroupAlias(groupKey, alias string, ctx context.Context) error {
+	err := receiver.retry("DeleteGroupAlias", ctx, func() error {
+		return receiver.Service.Groups.Aliases.Delete(groupKey, alias).Context(ctx).Do()
+	})
+	if err != nil {
+		receiver.logger().Println(err.Error())
+		return err
+	}
+	receiver.logger().Printf("Alias [%s] removed from group (%s)\n", alias, groupKey)
+	return nil
+}
+
+// aliasNames decodes admin.Aliases, whose entries the generated client leaves as untyped JSON.
+func aliasNames(op string, response *admin.Aliases) ([]string, error) {
+	data, err := json.Marshal(response.Aliases)
+	if err != nil {
+		return nil, wrapError(op, err)
+	}
+	var aliases []admin.Alias
+	if err = json.Unmarshal(data, &aliases); err != nil {
+		return nil, wrapError(op, err)
+	}
+	names := make([]string, 0, len(aliases))
+	for _, alias := range aliases {
+		names = append(names, alias.Alias)
+	}
+	return names, nil
+}
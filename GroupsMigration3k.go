@@ -11,55 +11,94 @@ import (
 	"google.golang.org/api/option"
 	"log"
 	"net/http"
-	"strings"
 )
 
 type GroupsMigration3k struct {
 	Service    *groupsmigration.Service
+	CustomerID string
 	AdminEmail string
 	Domain     string
+	Options3k
 }
 
-func BuildGroupsMigration3k(client *http.Client, adminEmail string, ctx context.Context) *GroupsMigration3k {
-	groupMigration3k := &GroupsMigration3k{}
+func BuildGroupsMigration3k(client *http.Client, adminEmail string, ctx context.Context) (*GroupsMigration3k, error) {
+	return buildGroupsMigration3k(client, adminEmail, "", Options3k{}, ctx)
+}
+
+func buildGroupsMigration3k(client *http.Client, adminEmail, customerID string, options Options3k, ctx context.Context) (*GroupsMigration3k, error) {
+	groupMigration3k := &GroupsMigration3k{Options3k: options}
+	domain, err := domainFromEmail("BuildGroupsMigration3k", adminEmail)
+	if err != nil {
+		groupMigration3k.logger().Println(err.Error())
+		return nil, err
+	}
 	service, err := groupsmigration.NewService(ctx, option.WithHTTPClient(client))
 	if err != nil {
-		log.Println(err.Error())
-		panic(err)
+		groupMigration3k.logger().Println(err.Error())
+		return nil, wrapError("BuildGroupsMigration3k", err)
 	}
 	groupMigration3k.Service = service
+	groupMigration3k.CustomerID = customerID
 	groupMigration3k.AdminEmail = adminEmail
-	groupMigration3k.Domain = strings.Split(adminEmail, "@")[1]
-	log.Printf("GroupMigration -->Service: %v,\tCustomerID: %s,\tAdminEmail: %s,\tDomain: %s\n",
-		&groupMigration3k.Service, groupMigration3k.AdminEmail, groupMigration3k.Domain)
-	return groupMigration3k
+	groupMigration3k.Domain = domain
+	groupMigration3k.logger().Printf("GroupMigration -->Service: %v,\tCustomerID: %s,\tAdminEmail: %s,\tDomain: %s\n",
+		&groupMigration3k.Service, groupMigration3k.CustomerID, groupMigration3k.AdminEmail, groupMigration3k.Domain)
+	return groupMigration3k, nil
 }
 
-func BuildGroupsMigration3kOauth2(adminEmail string, scopes []string, clientSecret, authorizationToken []byte, ctx context.Context) *GroupsMigration3k {
+func BuildGroupsMigration3kOauth2(adminEmail string, scopes []string, clientSecret, authorizationToken []byte, ctx context.Context) (*GroupsMigration3k, error) {
 	config, err := google.ConfigFromJSON(clientSecret, scopes...)
 	if err != nil {
 		log.Println(err.Error())
-		panic(err)
+		return nil, wrapError("BuildGroupsMigration3kOauth2", err)
 	}
 	token := &oauth2.Token{}
 	err = json.Unmarshal(authorizationToken, token)
 	if err != nil {
 		log.Println(err.Error())
-		panic(err)
+		return nil, wrapError("BuildGroupsMigration3kOauth2", err)
 	}
 	client := config.Client(context.Background(), token)
 	return BuildGroupsMigration3k(client, adminEmail, ctx)
 }
 
-func (receiver *GroupsMigration3k) InsertEmail(groupEmail string, emailData []byte) (*groupsmigration.Groups, error) {
-	media := bytes.NewReader(emailData)
+func BuildGroupsMigration3kTokenStore(adminEmail string, scopes []string, clientSecret []byte, store TokenStore, ctx context.Context) (*GroupsMigration3k, error) {
+	client, err := TokenStoreClient(clientSecret, scopes, store)
+	if err != nil {
+		return nil, err
+	}
+	return BuildGroupsMigration3k(client, adminEmail, ctx)
+}
+
+func BuildGroupsMigration3kServiceAccount(adminEmail string, scopes []string, serviceAccountKey []byte, ctx context.Context) (*GroupsMigration3k, error) {
+	client, err := ServiceAccountClient(serviceAccountKey, adminEmail, scopes)
+	if err != nil {
+		return nil, err
+	}
+	return BuildGroupsMigration3k(client, adminEmail, ctx)
+}
+
+func BuildGroupsMigration3kDefaultCredentials(adminEmail, serviceAccountEmail string, scopes []string, ctx context.Context) (*GroupsMigration3k, error) {
+	client, err := DefaultCredentialsClient(serviceAccountEmail, adminEmail, scopes, ctx)
+	if err != nil {
+		return nil, err
+	}
+	return BuildGroupsMigration3k(client, adminEmail, ctx)
+}
+
+func (receiver *GroupsMigration3k) InsertEmail(groupEmail string, emailData []byte, ctx context.Context) (*groupsmigration.Groups, error) {
 	mediaOption := googleapi.ContentType("message/rfc822")
-	response, err := receiver.Service.Archive.Insert(groupEmail).Media(media, mediaOption).Do()
+	var response *groupsmigration.Groups
+	err := receiver.retry("InsertEmail", ctx, func() (err error) {
+		media := bytes.NewReader(emailData)
+		response, err = receiver.Service.Archive.Insert(groupEmail).Media(media, mediaOption).Context(ctx).Do()
+		return err
+	})
 	if err != nil {
-		log.Println(err.Error())
-		log.Printf("Mailbox [%s] import of (%d) bytes - FAILED\n", groupEmail, len(emailData))
+		receiver.logger().Println(err.Error())
+		receiver.logger().Printf("Mailbox [%s] import of (%d) bytes - FAILED\n", groupEmail, len(emailData))
 		return nil, err
 	}
-	log.Printf("Mailbox [%s] import of (%d) bytes - SUCCESS\n", groupEmail, len(emailData))
+	receiver.logger().Printf("Mailbox [%s] import of (%d) bytes - SUCCESS\n", groupEmail, len(emailData))
 	return response, nil
 }
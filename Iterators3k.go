@@ -0,0 +1,175 @@
+package googleadmin3k
+
+import (
+	"context"
+	admin "google.golang.org/api/admin/directory/v1"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/iterator"
+	"strings"
+)
+
+// PageIterator yields API results one page at a time. Next and NextPage return
+// iterator.Done once the last page has been consumed.
+type PageIterator[T any] struct {
+	fetch     func(pageToken string) ([]T, string, error)
+	buffer    []T
+	pageToken string
+	done      bool
+}
+
+type UsersIterator = PageIterator[*admin.User]
+type GroupsIterator = PageIterator[*admin.Group]
+type MembersIterator = PageIterator[*admin.Member]
+
+func newPageIterator[T any](fetch func(pageToken string) ([]T, string, error)) *PageIterator[T] {
+	return &PageIterator[T]{fetch: fetch}
+}
+
+func (receiver *PageIterator[T]) Next() (T, error) {
+	for len(receiver.buffer) == 0 {
+		page, err := receiver.NextPage()
+		if err != nil {
+			var zero T
+			return zero, err
+		}
+		receiver.buffer = page
+	}
+	item := receiver.buffer[0]
+	receiver.buffer = receiver.buffer[1:]
+	return item, nil
+}
+
+// NextPage fetches the next page, discarding anything Next left buffered from the previous one.
+// A failed fetch can be retried by calling NextPage again.
+func (receiver *PageIterator[T]) NextPage() ([]T, error) {
+	receiver.buffer = nil
+	if receiver.done {
+		return nil, iterator.Done
+	}
+	items, nextPageToken, err := receiver.fetch(receiver.pageToken)
+	if err != nil {
+		return nil, err
+	}
+	receiver.pageToken = nextPageToken
+	receiver.done = nextPageToken == ""
+	return items, nil
+}
+
+// PageToken is the token of the next page to be fetched, empty once iteration is complete.
+// Pass it to SetPageToken on a new iterator to resume after the last fetched page.
+func (receiver *PageIterator[T]) PageToken() string {
+	return receiver.pageToken
+}
+
+func (receiver *PageIterator[T]) SetPageToken(pageToken string) {
+	receiver.pageToken = pageToken
+	receiver.buffer = nil
+	receiver.done = false
+}
+
+// collectPages drains iterator into one slice, returning what was read before any error.
+func collectPages[T any](pages *PageIterator[T]) ([]T, error) {
+	var items []T
+	for {
+		page, err := pages.NextPage()
+		if err == iterator.Done {
+			return items, nil
+		}
+		if err != nil {
+			return items, err
+		}
+		items = append(items, page...)
+	}
+}
+
+/*Directory iterators*/
+func (receiver *Directory3k) UsersIterator(query string, ctx context.Context, fields ...googleapi.Field) *UsersIterator {
+	return receiver.usersIterator(func() *admin.UsersListCall { return receiver.Service.Users.List().Domain(receiver.Domain) }, query, ctx, fields...)
+}
+
+// CustomerUsersIterator walks the users of every domain of the customer, not only Domain.
+func (receiver *Directory3k) CustomerUsersIterator(query string, ctx context.Context, fields ...googleapi.Field) *UsersIterator {
+	return receiver.usersIterator(func() *admin.UsersListCall { return receiver.Service.Users.List().Customer(receiver.CustomerID) }, query, ctx, fields...)
+}
+
+func (receiver *Directory3k) usersIterator(list func() *admin.UsersListCall, query string, ctx context.Context, fields ...googleapi.Field) *UsersIterator {
+	return newPageIterator(func(pageToken string) ([]*admin.User, string, error) {
+		request := list().Fields(listFields("users", fields)).Query(query).MaxResults(500).Context(ctx)
+		if pageToken != "" {
+			request.PageToken(pageToken)
+		}
+		var response *admin.Users
+		err := receiver.retry("UsersIterator", ctx, func() (err error) {
+			response, err = request.Do()
+			return err
+		})
+		if err != nil {
+			receiver.logger().Println(err.Error())
+			return nil, "", err
+		}
+		return response.Users, response.NextPageToken, nil
+	})
+}
+
+func (receiver *Directory3k) GroupsIterator(query string, ctx context.Context, fields ...googleapi.Field) *GroupsIterator {
+	return receiver.groupsIterator(func() *admin.GroupsListCall { return receiver.Service.Groups.List().Domain(receiver.Domain) }, query, ctx, fields...)
+}
+
+// CustomerGroupsIterator walks the groups of every domain of the customer, not only Domain.
+func (receiver *Directory3k) CustomerGroupsIterator(query string, ctx context.Context, fields ...googleapi.Field) *GroupsIterator {
+	return receiver.groupsIterator(func() *admin.GroupsListCall { return receiver.Service.Groups.List().Customer(receiver.CustomerID) }, query, ctx, fields...)
+}
+
+func (receiver *Directory3k) groupsIterator(list func() *admin.GroupsListCall, query string, ctx context.Context, fields ...googleapi.Field) *GroupsIterator {
+	return newPageIterator(func(pageToken string) ([]*admin.Group, string, error) {
+		request := list().Fields(listFields("groups", fields)).Context(ctx)
+		if query != "" {
+			request.Query(query)
+		}
+		if pageToken != "" {
+			request.PageToken(pageToken)
+		}
+		var response *admin.Groups
+		err := receiver.retry("GroupsIterator", ctx, func() (err error) {
+			response, err = request.Do()
+			return err
+		})
+		if err != nil {
+			receiver.logger().Println(err.Error())
+			return nil, "", err
+		}
+		return response.Groups, response.NextPageToken, nil
+	})
+}
+
+func (receiver *Directory3k) MembersIterator(groupEmail string, ctx context.Context, fields ...googleapi.Field) *MembersIterator {
+	return receiver.FilteredMembersIterator(groupEmail, nil, ctx, fields...)
+}
+
+// FilteredMembersIterator lists only the members matching filter. Roles and derived membership are
+// resolved by the API, the other criteria are applied to each page as it arrives.
+func (receiver *Directory3k) FilteredMembersIterator(groupEmail string, filter *MemberFilter, ctx context.Context, fields ...googleapi.Field) *MembersIterator {
+	fields = filter.requiredFields(fields)
+	return newPageIterator(func(pageToken string) ([]*admin.Member, string, error) {
+		request := receiver.Service.Members.List(groupEmail).Fields(listFields("members", fields)).MaxResults(200).Context(ctx)
+		if pageToken != "" {
+			request.PageToken(pageToken)
+		}
+		if filter != nil && len(filter.Roles) > 0 {
+			request.Roles(strings.ToUpper(strings.Join(filter.Roles, ",")))
+		}
+		if filter != nil && filter.IncludeDerivedMembership {
+			request.IncludeDerivedMembership(true)
+		}
+		var response *admin.Members
+		err := receiver.retry("MembersIterator", ctx, func() (err error) {
+			response, err = request.Do()
+			return err
+		})
+		if err != nil {
+			receiver.logger().Println(err.Error())
+			return nil, "", err
+		}
+		return filter.apply(response.Members), response.NextPageToken, nil
+	})
+}
@@ -0,0 +1,323 @@
+package googleadmin3k
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"google.golang.org/api/licensing/v1"
+	"io"
+	"sort"
+	"strings"
+)
+
+/*License change actions*/
+const (
+	LicenseInsert = "INSERT"
+	LicenseUpdate = "UPDATE"
+	LicenseDelete = "DELETE"
+)
+
+type LicenseReconcileOptions struct {
+	DryRun bool
+	// Users missing from the desired mapping keep their licenses unless set.
+	RemoveUnlisted bool
+	MaxRoutines    int
+	MaxResults     int64
+}
+
+// LicenseChange is one call of the plan. Before is nil for an insert and After is nil for a delete.
+type LicenseChange struct {
+	Action string
+	UserID string
+	Before *Product
+	After  *Product
+	Err    error
+}
+
+func (receiver *LicenseChange) String() string {
+	switch receiver.Action {
+	case LicenseInsert:
+		return fmt.Sprintf("%s (%s) <%s>", receiver.Action, receiver.UserID, receiver.After.SKUName)
+	case LicenseUpdate:
+		return fmt.Sprintf("%s (%s) <%s> -> <%s>", receiver.Action, receiver.UserID, receiver.Before.SKUName, receiver.After.SKUName)
+	default:
+		return fmt.Sprintf("%s (%s) <%s>", receiver.Action, receiver.UserID, receiver.Before.SKUName)
+	}
+}
+
+type LicenseReport struct {
+	DryRun    bool
+	Changes   []*LicenseChange
+	Unchanged int
+}
+
+func (receiver *LicenseReport) Count(action string) int {
+	count := 0
+	for _, change := range receiver.Changes {
+		if change.Action == action {
+			count++
+		}
+	}
+	return count
+}
+
+func (receiver *LicenseReport) Failed() []*LicenseChange {
+	var failed []*LicenseChange
+	for _, change := range receiver.Changes {
+		if change.Err != nil {
+			failed = append(failed, change)
+		}
+	}
+	return failed
+}
+
+// CSV writes one row per change with its outcome.
+func (receiver *LicenseReport) CSV(writer io.Writer) error {
+	csvWriter := csv.NewWriter(writer)
+	csvWriter.Write([]string{"action", "user", "from_sku", "to_sku", "status", "error"})
+	for _, change := range receiver.Changes {
+		var from, to, status, message string
+		if change.Before != nil {
+			from = change.Before.SKUName
+		}
+		if change.After != nil {
+			to = change.After.SKUName
+		}
+		switch {
+		case receiver.DryRun:
+			status = "PLANNED"
+		case change.Err != nil:
+			status, message = BulkFailed, change.Err.Error()
+		default:
+			status = BulkSucceeded
+		}
+		csvWriter.Write([]string{change.Action, change.UserID, from, to, status, message})
+	}
+	csvWriter.Flush()
+	return csvWriter.Error()
+}
+
+// ReconcileLicenses brings the assignments of the managed products in line with desired, which maps each user
+// to the products they should hold, at most one SKU per ProductID. A user mapped to no products loses every
+// managed license. Products in desired are managed even when missing from managed.
+func (receiver *Licensing3k) ReconcileLicenses(desired map[string][]Product, managed []Product, options *LicenseReconcileOptions, ctx context.Context) (*LicenseReport, error) {
+	if options == nil {
+		options = &LicenseReconcileOptions{}
+	}
+	maxResults := options.MaxResults
+	if maxResults <= 0 {
+		maxResults = 1000
+	}
+	// Each product is listed once, a repeated one would double its assignments and plan a delete next to the
+	// swap of the same license.
+	var products []Product
+	seen := make(map[Product]bool)
+	addProducts := func(candidates []Product) {
+		for _, product := range candidates {
+			if !seen[product] {
+				seen[product] = true
+				products = append(products, product)
+			}
+		}
+	}
+	addProducts(managed)
+	for _, userProducts := range desired {
+		addProducts(userProducts)
+	}
+
+	// Planning on a partial view would insert licenses users already hold, so any listing error stops here.
+	current, err := receiver.GetLicensesMap(products, maxResults, options.MaxRoutines, ctx, LicenseFieldsUser)
+	if err != nil {
+		return nil, err
+	}
+	report := &LicenseReport{DryRun: options.DryRun}
+	report.Changes, report.Unchanged, err = planLicenseChanges(desired, current, options.RemoveUnlisted)
+	if err != nil {
+		receiver.logger().Println(err.Error())
+		return nil, err
+	}
+	receiver.logger().Printf("License reconcile: %d to insert, %d to update, %d to delete, %d unchanged\n",
+		report.Count(LicenseInsert), report.Count(LicenseUpdate), report.Count(LicenseDelete), report.Unchanged)
+	if options.DryRun {
+		return report, nil
+	}
+
+	results := runPool(report.Changes, func(change *LicenseChange) string { return change.UserID }, options.MaxRoutines, nil, ctx, func(change *LicenseChange) error {
+		return receiver.applyLicenseChange(change, ctx)
+	})
+	for i, result := range results {
+		report.Changes[i].Err = result.Err
+	}
+	if failed := report.Failed(); len(failed) > 0 {
+		return report, failed[0].Err
+	}
+	return report, nil
+}
+
+func (receiver *Licensing3k) applyLicenseChange(change *LicenseChange, ctx context.Context) (err error) {
+	switch change.Action {
+	case LicenseInsert:
+		_, err = receiver.Insert(change.After, change.UserID, ctx)
+	case LicenseUpdate:
+		_, err = receiver.SwapSKU(change.UserID, change.Before, change.After, ctx)
+	case LicenseDelete:
+		err = receiver.Delete(change.Before, change.UserID, ctx)
+	}
+	return err
+}
+
+func planLicenseChanges(desired map[string][]Product, current map[Product][]*licensing.LicenseAssignment, removeUnlisted bool) ([]*LicenseChange, int, error) {
+	// held[user][productID] are the SKUs of that product the user has now.
+	held := make(map[string]map[string][]Product)
+	userIDs := make(map[string]string)
+	for product, assignments := range current {
+		for _, assignment := range assignments {
+			user := strings.ToLower(assignment.UserId)
+			userIDs[user] = assignment.UserId
+			if held[user] == nil {
+				held[user] = make(map[string][]Product)
+			}
+			if !containsProduct(held[user][product.ProductID], product) {
+				held[user][product.ProductID] = append(held[user][product.ProductID], product)
+			}
+		}
+	}
+	for _, products := range held {
+		for _, skus := range products {
+			sort.Slice(skus, func(i, j int) bool { return skus[i].SKUID < skus[j].SKUID })
+		}
+	}
+	wanted := make(map[string]map[string]Product)
+	for userID, products := range desired {
+		user := strings.ToLower(userID)
+		if _, exists := userIDs[user]; !exists {
+			userIDs[user] = userID
+		}
+		if wanted[user] == nil {
+			wanted[user] = make(map[string]Product)
+		}
+		for _, product := range products {
+			if other, exists := wanted[user][product.ProductID]; exists && other != product {
+				return nil, 0, invalidArgument("ReconcileLicenses", "(%s) is given both <%s> and <%s> of %s", userID, other.SKUName, product.SKUName, product.ProductID)
+			}
+			wanted[user][product.ProductID] = product
+		}
+	}
+
+	users := make([]string, 0, len(userIDs))
+	for user := range userIDs {
+		users = append(users, user)
+	}
+	sort.Strings(users)
+
+	var changes []*LicenseChange
+	unchanged := 0
+	for _, user := range users {
+		userID := userIDs[user]
+		userWanted, listed := wanted[user]
+		for _, productID := range sortedKeys(userWanted) {
+			want := userWanted[productID]
+			have := held[user][productID]
+			switch {
+			case containsProduct(have, want):
+				unchanged++
+			case len(have) > 0:
+				changes = append(changes, &LicenseChange{Action: LicenseUpdate, UserID: userID, Before: productRef(have[0]), After: productRef(want)})
+				have = have[1:]
+			default:
+				changes = append(changes, &LicenseChange{Action: LicenseInsert, UserID: userID, After: productRef(want)})
+			}
+			for _, extra := range have {
+				if extra != want {
+					changes = append(changes, &LicenseChange{Action: LicenseDelete, UserID: userID, Before: productRef(extra)})
+				}
+			}
+		}
+		if !listed && !removeUnlisted {
+			continue
+		}
+		for _, productID := range sortedKeys(held[user]) {
+			if _, kept := userWanted[productID]; kept {
+				continue
+			}
+			for _, product := range held[user][productID] {
+				changes = append(changes, &LicenseChange{Action: LicenseDelete, UserID: userID, Before: productRef(product)})
+			}
+		}
+	}
+	return changes, unchanged, nil
+}
+
+func sortedKeys[V any](values map[string]V) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func containsProduct(products []Product, product Product) bool {
+	for _, candidate := range products {
+		if candidate == product {
+			return true
+		}
+	}
+	return false
+}
+
+func productRef(product Product) *Product {
+	return &product
+}
+
+// LoadDesiredLicenses reads a CSV with a header holding "user" and "sku" columns, one row per license. The sku
+// is a SKU ID or SKU name known to DefaultCatalog, and a row with an empty sku lists a user who should hold
+// no managed licenses.
+func LoadDesiredLicenses(reader io.Reader) (map[string][]Product, error) {
+	csvReader := csv.NewReader(reader)
+	csvReader.TrimLeadingSpace = true
+	header, err := csvReader.Read()
+	if err != nil {
+		return nil, wrapError("LoadDesiredLicenses", err)
+	}
+	userColumn, skuColumn := -1, -1
+	for i, name := range header {
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "user":
+			userColumn = i
+		case "sku":
+			skuColumn = i
+		}
+	}
+	if userColumn < 0 || skuColumn < 0 {
+		return nil, invalidArgument("LoadDesiredLicenses", "header needs a user and a sku column, got %v", header)
+	}
+
+	desired := make(map[string][]Product)
+	for {
+		record, err := csvReader.Read()
+		if errors.Is(err, io.EOF) {
+			return desired, nil
+		}
+		if err != nil {
+			return nil, wrapError("LoadDesiredLicenses", err)
+		}
+		line, _ := csvReader.FieldPos(0)
+		user, sku := strings.TrimSpace(record[userColumn]), strings.TrimSpace(record[skuColumn])
+		if user == "" {
+			return nil, invalidArgument("LoadDesiredLicenses", "line %d has no user", line)
+		}
+		if sku == "" {
+			if _, listed := desired[user]; !listed {
+				desired[user] = nil
+			}
+			continue
+		}
+		product, ok := DefaultCatalog.Lookup(sku)
+		if !ok {
+			return nil, invalidArgument("LoadDesiredLicenses", "line %d: unknown sku %q", line, sku)
+		}
+		desired[user] = append(desired[user], product)
+	}
+}
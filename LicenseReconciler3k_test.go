@@ -0,0 +1,178 @@
+package googleadmin3k
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"google.golang.org/api/licensing/v1"
+	"google.golang.org/api/option"
+)
+
+func testProduct(t *testing.T, skuName string) Product {
+	t.Helper()
+	product, ok := DefaultCatalog.ByName(skuName)
+	if !ok {
+		t.Fatalf("no SKU named %q", skuName)
+	}
+	return product
+}
+
+// testAssignments builds the current assignments from each product to the users holding it.
+func testAssignments(holders map[Product][]string) map[Product][]*licensing.LicenseAssignment {
+	current := make(map[Product][]*licensing.LicenseAssignment)
+	for product, users := range holders {
+		for _, user := range users {
+			current[product] = append(current[product], &licensing.LicenseAssignment{ProductId: product.ProductID, SkuId: product.SKUID, UserId: user})
+		}
+	}
+	return current
+}
+
+func TestPlanLicenseChanges(t *testing.T) {
+	starter := testProduct(t, "Google Workspace Business Starter")
+	standard := testProduct(t, "Google Workspace Business Standard")
+	plus := testProduct(t, "Google Workspace Business Plus")
+	vault := testProduct(t, "Google Vault")
+
+	tests := []struct {
+		name           string
+		holders        map[Product][]string
+		desired        map[string][]Product
+		removeUnlisted bool
+		want           []string
+		wantUnchanged  int
+	}{
+		{
+			name:    "insert, update and keep",
+			holders: map[Product][]string{starter: {"a@example.com", "b@example.com"}},
+			desired: map[string][]Product{
+				"a@example.com": {standard},
+				"b@example.com": {starter},
+				"c@example.com": {starter, vault},
+			},
+			want: []string{
+				"UPDATE (a@example.com) <Google Workspace Business Starter> -> <Google Workspace Business Standard>",
+				"INSERT (c@example.com) <Google Workspace Business Starter>",
+				"INSERT (c@example.com) <Google Vault>",
+			},
+			wantUnchanged: 1,
+		},
+		{
+			name:    "duplicate assignments of one SKU",
+			holders: map[Product][]string{starter: {"a@example.com", "a@example.com", "b@example.com", "b@example.com"}},
+			desired: map[string][]Product{
+				"a@example.com": {standard},
+				"b@example.com": {},
+			},
+			want: []string{
+				"UPDATE (a@example.com) <Google Workspace Business Starter> -> <Google Workspace Business Standard>",
+				"DELETE (b@example.com) <Google Workspace Business Starter>",
+			},
+		},
+		{
+			name: "several SKUs of one product",
+			holders: map[Product][]string{
+				starter:  {"a@example.com", "b@example.com"},
+				standard: {"a@example.com"},
+				plus:     {"b@example.com"},
+			},
+			desired: map[string][]Product{
+				"a@example.com": {standard},
+				"b@example.com": {standard},
+			},
+			want: []string{
+				"DELETE (a@example.com) <Google Workspace Business Starter>",
+				"UPDATE (b@example.com) <Google Workspace Business Plus> -> <Google Workspace Business Standard>",
+				"DELETE (b@example.com) <Google Workspace Business Starter>",
+			},
+			wantUnchanged: 1,
+		},
+		{
+			name:          "unlisted users are kept",
+			holders:       map[Product][]string{starter: {"a@example.com", "b@example.com"}, vault: {"b@example.com"}},
+			desired:       map[string][]Product{"a@example.com": {starter}},
+			want:          nil,
+			wantUnchanged: 1,
+		},
+		{
+			name:           "unlisted users are removed",
+			holders:        map[Product][]string{starter: {"a@example.com", "b@example.com"}, vault: {"b@example.com"}},
+			desired:        map[string][]Product{"a@example.com": {starter}},
+			removeUnlisted: true,
+			want: []string{
+				"DELETE (b@example.com) <Google Workspace Business Starter>",
+				"DELETE (b@example.com) <Google Vault>",
+			},
+			wantUnchanged: 1,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			changes, unchanged, err := planLicenseChanges(test.desired, testAssignments(test.holders), test.removeUnlisted)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, change := range changes {
+				got = append(got, change.String())
+			}
+			if strings.Join(got, "\n") != strings.Join(test.want, "\n") {
+				t.Errorf("changes:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(test.want, "\n"))
+			}
+			if unchanged != test.wantUnchanged {
+				t.Errorf("unchanged = %d, want %d", unchanged, test.wantUnchanged)
+			}
+		})
+	}
+}
+
+func TestPlanLicenseChangesConflict(t *testing.T) {
+	desired := map[string][]Product{
+		"a@example.com": {testProduct(t, "Google Workspace Business Starter"), testProduct(t, "Google Workspace Business Standard")},
+	}
+	if _, _, err := planLicenseChanges(desired, nil, false); err == nil {
+		t.Error("two SKUs of one product for a user planned without error")
+	}
+}
+
+// A SKU given twice in managed must be listed once, or the plan deletes the license it swaps.
+func TestReconcileLicensesDuplicateManaged(t *testing.T) {
+	starter := testProduct(t, "Google Workspace Business Starter")
+	standard := testProduct(t, "Google Workspace Business Standard")
+	var lists int32
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		atomic.AddInt32(&lists, 1)
+		list := &licensing.LicenseAssignmentList{}
+		if strings.Contains(request.URL.Path, "/sku/"+starter.SKUID+"/") {
+			list.Items = []*licensing.LicenseAssignment{{ProductId: starter.ProductID, SkuId: starter.SKUID, UserId: "a@example.com"}}
+		}
+		json.NewEncoder(writer).Encode(list)
+	}))
+	defer server.Close()
+	service, err := licensing.NewService(context.Background(), option.WithEndpoint(server.URL+"/"), option.WithHTTPClient(server.Client()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	licensing3k := &Licensing3k{Service: service, CustomerID: "C123"}
+	licensing3k.RetryPolicy = NoRetry
+	licensing3k.Logger = log.New(io.Discard, "", 0)
+
+	desired := map[string][]Product{"a@example.com": {standard}}
+	report, err := licensing3k.ReconcileLicenses(desired, []Product{starter, standard, starter}, &LicenseReconcileOptions{DryRun: true}, context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Changes) != 1 || report.Changes[0].Action != LicenseUpdate {
+		t.Errorf("changes = %v, want one UPDATE", report.Changes)
+	}
+	if got := atomic.LoadInt32(&lists); got != 2 {
+		t.Errorf("listed %d SKUs, want 2", got)
+	}
+}
@@ -0,0 +1,270 @@
+package googleadmin3k
+
+import (
+	"context"
+	"fmt"
+	admin "google.golang.org/api/admin/directory/v1"
+	"sort"
+	"strings"
+)
+
+// LicenseRule gives Products to the users it matches. Every condition that is set must hold: the user is in
+// one of OrgUnits (or below it), is an effective member of one of Groups, and has the given Suspended and
+// Archived state. Exclusions win over conditions.
+type LicenseRule struct {
+	Name string
+	// Rules are evaluated by ascending Priority, rules with equal priority in the order given.
+	Priority  int
+	OrgUnits  []string
+	Groups    []string
+	Suspended *bool
+	Archived  *bool
+
+	ExcludeOrgUnits []string
+	ExcludeGroups   []string
+	ExcludeUsers    []string
+
+	// Products are tried in order, each one a fallback for when the seats of the previous one run out. A user
+	// gets at most one SKU per ProductID, from the first rule that grants one.
+	Products []Product
+	// Final stops the evaluation of lower priority rules for the users this rule matches.
+	Final bool
+}
+
+type LicenseRuleOptions struct {
+	// Query selects the users to evaluate, every user of the customer across all its domains when empty. With
+	// Reconcile.RemoveUnlisted set, users outside the query lose their managed licenses.
+	Query string
+	// Capacity is how many seats of each SKU, keyed by SKUID, may be handed out. SKUs missing from it are unlimited.
+	Capacity map[string]int
+	// Reconcile is used by ApplyLicenseRules.
+	Reconcile *LicenseReconcileOptions
+}
+
+// UserLicensePlan is the outcome for one user, with a trace line for every rule explaining it.
+type UserLicensePlan struct {
+	UserEmail string
+	Products  []Product
+	Trace     []string
+}
+
+type LicenseEvaluation struct {
+	Users []*UserLicensePlan
+	// Remaining is the capacity left per SKUID once every user got their products.
+	Remaining map[string]int
+}
+
+// Desired is the evaluation in the form ReconcileLicenses takes.
+func (receiver *LicenseEvaluation) Desired() map[string][]Product {
+	desired := make(map[string][]Product, len(receiver.Users))
+	for _, plan := range receiver.Users {
+		desired[plan.UserEmail] = plan.Products
+	}
+	return desired
+}
+
+// EvaluateLicenseRules works out the products each user should hold without changing anything.
+func (receiver *Workspace3k) EvaluateLicenseRules(rules []*LicenseRule, options *LicenseRuleOptions, ctx context.Context) (*LicenseEvaluation, error) {
+	if options == nil {
+		options = &LicenseRuleOptions{}
+	}
+	directory, err := receiver.Directory(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// Licenses are assigned per customer, so users of secondary domains are evaluated too.
+	users, err := collectPages(directory.CustomerUsersIterator(options.Query, ctx, UserFieldsBasic))
+	if err != nil {
+		return nil, err
+	}
+
+	var groupEmails []string
+	for _, rule := range rules {
+		groupEmails = append(groupEmails, rule.Groups...)
+		groupEmails = append(groupEmails, rule.ExcludeGroups...)
+	}
+	groupMembers := make(map[string]map[string]bool)
+	for _, groupEmail := range groupEmails {
+		groupMembers[strings.ToLower(groupEmail)] = nil
+	}
+	if len(groupMembers) > 0 {
+		graph, err := directory.BuildGroupGraph(sortedKeys(groupMembers), ctx)
+		if err != nil {
+			return nil, err
+		}
+		for groupEmail := range groupMembers {
+			members := make(map[string]bool)
+			for _, member := range graph.Expand(groupEmail) {
+				members[member.Email] = true
+			}
+			groupMembers[groupEmail] = members
+		}
+	}
+
+	evaluation := evaluateLicenseRules(rules, users, groupMembers, options.Capacity)
+	receiver.logger().Printf("License rules: %d rules evaluated for %d users\n", len(rules), len(evaluation.Users))
+	return evaluation, nil
+}
+
+// ApplyLicenseRules evaluates rules and reconciles the licenses of every product the rules mention, so users
+// matched by no rule lose those licenses.
+func (receiver *Workspace3k) ApplyLicenseRules(rules []*LicenseRule, options *LicenseRuleOptions, ctx context.Context) (*LicenseEvaluation, *LicenseReport, error) {
+	if options == nil {
+		options = &LicenseRuleOptions{}
+	}
+	evaluation, err := receiver.EvaluateLicenseRules(rules, options, ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	licensing3k, err := receiver.Licensing(ctx)
+	if err != nil {
+		return evaluation, nil, err
+	}
+	// Rules often share a SKU, a main rule and its fallback for example, and each SKU is managed once.
+	var managed []Product
+	seen := make(map[string]bool)
+	for _, rule := range rules {
+		for _, product := range rule.Products {
+			if !seen[product.SKUID] {
+				seen[product.SKUID] = true
+				managed = append(managed, product)
+			}
+		}
+	}
+	report, err := licensing3k.ReconcileLicenses(evaluation.Desired(), managed, options.Reconcile, ctx)
+	return evaluation, report, err
+}
+
+// evaluateLicenseRules hands out seats to users in order of their email, so a rerun over the same data gives
+// the same result. groupMembers holds the effective members of every group the rules name.
+func evaluateLicenseRules(rules []*LicenseRule, users []*admin.User, groupMembers map[string]map[string]bool, capacity map[string]int) *LicenseEvaluation {
+	ordered := append([]*LicenseRule{}, rules...)
+	sort.SliceStable(ordered, func(i, j int) bool { return ordered[i].Priority < ordered[j].Priority })
+	users = append([]*admin.User{}, users...)
+	sort.Slice(users, func(i, j int) bool {
+		return strings.ToLower(users[i].PrimaryEmail) < strings.ToLower(users[j].PrimaryEmail)
+	})
+
+	evaluation := &LicenseEvaluation{Remaining: make(map[string]int, len(capacity))}
+	for skuID, seats := range capacity {
+		evaluation.Remaining[skuID] = seats
+	}
+	for _, user := range users {
+		plan := &UserLicensePlan{UserEmail: user.PrimaryEmail}
+		grantedBy := make(map[string]string)
+		finalRule := ""
+		for _, rule := range ordered {
+			label := fmt.Sprintf("rule %q (priority %d)", rule.Name, rule.Priority)
+			if finalRule != "" {
+				plan.Trace = append(plan.Trace, fmt.Sprintf("%s: skipped, rule %q is final", label, finalRule))
+				continue
+			}
+			if reason, matched := rule.match(user, groupMembers); !matched {
+				plan.Trace = append(plan.Trace, fmt.Sprintf("%s: %s", label, reason))
+				continue
+			}
+			if rule.Final {
+				finalRule = rule.Name
+			}
+			for _, productID := range ruleProductIDs(rule) {
+				if by, granted := grantedBy[productID]; granted {
+					plan.Trace = append(plan.Trace, fmt.Sprintf("%s: %s already granted by rule %q", label, productID, by))
+					continue
+				}
+				var exhausted []string
+				for _, product := range rule.Products {
+					if product.ProductID != productID {
+						continue
+					}
+					if seats, limited := evaluation.Remaining[product.SKUID]; limited && seats <= 0 {
+						exhausted = append(exhausted, product.SKUName)
+						continue
+					}
+					if _, limited := evaluation.Remaining[product.SKUID]; limited {
+						evaluation.Remaining[product.SKUID]--
+					}
+					plan.Products = append(plan.Products, product)
+					grantedBy[productID] = rule.Name
+					line := fmt.Sprintf("%s: assigned <%s>", label, product.SKUName)
+					if len(exhausted) > 0 {
+						line += fmt.Sprintf(" as fallback, no seats left for <%s>", strings.Join(exhausted, ">, <"))
+					}
+					plan.Trace = append(plan.Trace, line)
+					break
+				}
+				if _, granted := grantedBy[productID]; !granted {
+					plan.Trace = append(plan.Trace, fmt.Sprintf("%s: no seats left for <%s>", label, strings.Join(exhausted, ">, <")))
+				}
+			}
+		}
+		evaluation.Users = append(evaluation.Users, plan)
+	}
+	return evaluation
+}
+
+// match reports whether the rule applies to user, and why not when it does not.
+func (receiver *LicenseRule) match(user *admin.User, groupMembers map[string]map[string]bool) (string, bool) {
+	email := strings.ToLower(user.PrimaryEmail)
+	for _, excluded := range receiver.ExcludeUsers {
+		if strings.EqualFold(excluded, email) {
+			return "user is excluded", false
+		}
+	}
+	if orgUnit, in := inOrgUnits(user.OrgUnitPath, receiver.ExcludeOrgUnits); in {
+		return fmt.Sprintf("excluded by org unit %s", orgUnit), false
+	}
+	for _, groupEmail := range receiver.ExcludeGroups {
+		if groupMembers[strings.ToLower(groupEmail)][email] {
+			return fmt.Sprintf("excluded by group %s", groupEmail), false
+		}
+	}
+	if len(receiver.OrgUnits) > 0 {
+		if _, in := inOrgUnits(user.OrgUnitPath, receiver.OrgUnits); !in {
+			return fmt.Sprintf("org unit %s not matched", user.OrgUnitPath), false
+		}
+	}
+	if len(receiver.Groups) > 0 {
+		member := false
+		for _, groupEmail := range receiver.Groups {
+			if groupMembers[strings.ToLower(groupEmail)][email] {
+				member = true
+				break
+			}
+		}
+		if !member {
+			return "not a member of any rule group", false
+		}
+	}
+	if receiver.Suspended != nil && user.Suspended != *receiver.Suspended {
+		return fmt.Sprintf("suspended is %t", user.Suspended), false
+	}
+	if receiver.Archived != nil && user.Archived != *receiver.Archived {
+		return fmt.Sprintf("archived is %t", user.Archived), false
+	}
+	return "", true
+}
+
+// inOrgUnits matches orgUnitPath against each path and everything below it.
+func inOrgUnits(orgUnitPath string, orgUnits []string) (string, bool) {
+	path := strings.ToLower(orgUnitPath)
+	for _, orgUnit := range orgUnits {
+		prefix := strings.ToLower(strings.TrimSuffix(orgUnit, "/"))
+		if prefix == "" || path == prefix || strings.HasPrefix(path, prefix+"/") {
+			return orgUnit, true
+		}
+	}
+	return "", false
+}
+
+// ruleProductIDs are the distinct products of a rule in the order they first appear.
+func ruleProductIDs(rule *LicenseRule) []string {
+	var productIDs []string
+	seen := make(map[string]bool)
+	for _, product := range rule.Products {
+		if !seen[product.ProductID] {
+			seen[product.ProductID] = true
+			productIDs = append(productIDs, product.ProductID)
+		}
+	}
+	return productIDs
+}
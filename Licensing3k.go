@@ -3,16 +3,20 @@ package googleadmin3k
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/google"
+	"google.golang.org/api/googleapi"
 	"google.golang.org/api/licensing/v1"
 	"google.golang.org/api/option"
 	"log"
 	"net/http"
+	"sort"
 	"strings"
-	"sync"
 )
 
+// AllProducts are the SKUs this package first shipped with. DefaultCatalog holds these and every SKU added since.
 var AllProducts = []Product{
 	GoogleWorkspaceBusinessStarter,
 	GoogleWorkspaceBusinessStandard,
@@ -36,131 +40,252 @@ type Licensing3k struct {
 	CustomerID string
 	AdminEmail string
 	Domain     string
+	Options3k
 }
 
-func BuildLicensing3k(client *http.Client, adminEmail, customerID string, ctx context.Context) *Licensing3k {
-	var newLicensingAPI = &Licensing3k{}
+func BuildLicensing3k(client *http.Client, adminEmail, customerID string, ctx context.Context) (*Licensing3k, error) {
+	return buildLicensing3k(client, adminEmail, customerID, Options3k{}, ctx)
+}
+
+func buildLicensing3k(client *http.Client, adminEmail, customerID string, options Options3k, ctx context.Context) (*Licensing3k, error) {
+	var newLicensingAPI = &Licensing3k{Options3k: options}
+	domain, err := domainFromEmail("BuildLicensing3k", adminEmail)
+	if err != nil {
+		newLicensingAPI.logger().Println(err.Error())
+		return nil, err
+	}
 	service, err := licensing.NewService(ctx, option.WithHTTPClient(client))
 	if err != nil {
-		log.Println(err.Error())
-		panic(err)
+		newLicensingAPI.logger().Println(err.Error())
+		return nil, wrapError("BuildLicensing3k", err)
 	}
 	newLicensingAPI.Service = service
 	newLicensingAPI.CustomerID = customerID
 	newLicensingAPI.AdminEmail = adminEmail
-	newLicensingAPI.Domain = strings.Split(adminEmail, "@")[1]
-	log.Printf("Licensing3k --> \n"+
+	newLicensingAPI.Domain = domain
+	newLicensingAPI.logger().Printf("Licensing3k --> \n"+
 		"\tService: %v\n"+
 		"\tCustomerID: %s\n"+
 		"\tAdminEmail: %s\n"+
 		"\tDomain: %s\n", &newLicensingAPI.Service, newLicensingAPI.CustomerID, newLicensingAPI.AdminEmail, newLicensingAPI.Domain,
 	)
-	return newLicensingAPI
+	return newLicensingAPI, nil
 }
 
-func BuildLicensingApiWithOauth2(adminEmail, customerId string, scopes []string, clientSecret, authorizationToken []byte, ctx context.Context) *Licensing3k {
+func BuildLicensingApiWithOauth2(adminEmail, customerId string, scopes []string, clientSecret, authorizationToken []byte, ctx context.Context) (*Licensing3k, error) {
 	config, err := google.ConfigFromJSON(clientSecret, scopes...)
 	if err != nil {
 		log.Println(err.Error())
-		panic(err)
+		return nil, wrapError("BuildLicensingApiWithOauth2", err)
 	}
 	token := &oauth2.Token{}
 	err = json.Unmarshal(authorizationToken, token)
 	if err != nil {
 		log.Println(err.Error())
-		panic(err)
+		return nil, wrapError("BuildLicensingApiWithOauth2", err)
 	}
 	client := config.Client(context.Background(), token)
 	return BuildLicensing3k(client, adminEmail, customerId, ctx)
 }
 
+func BuildLicensing3kTokenStore(adminEmail, customerID string, scopes []string, clientSecret []byte, store TokenStore, ctx context.Context) (*Licensing3k, error) {
+	client, err := TokenStoreClient(clientSecret, scopes, store)
+	if err != nil {
+		return nil, err
+	}
+	return BuildLicensing3k(client, adminEmail, customerID, ctx)
+}
+
+func BuildLicensing3kServiceAccount(adminEmail, customerID string, scopes []string, serviceAccountKey []byte, ctx context.Context) (*Licensing3k, error) {
+	client, err := ServiceAccountClient(serviceAccountKey, adminEmail, scopes)
+	if err != nil {
+		return nil, err
+	}
+	return BuildLicensing3k(client, adminEmail, customerID, ctx)
+}
+
+func BuildLicensing3kDefaultCredentials(adminEmail, customerID, serviceAccountEmail string, scopes []string, ctx context.Context) (*Licensing3k, error) {
+	client, err := DefaultCredentialsClient(serviceAccountEmail, adminEmail, scopes, ctx)
+	if err != nil {
+		return nil, err
+	}
+	return BuildLicensing3k(client, adminEmail, customerID, ctx)
+}
+
 /*Methods*/
-func (receiver *Licensing3k) GetLicenses(products []Product, maxResults int64) []*licensing.LicenseAssignment {
+// ProductErrors holds the error of each product whose licenses could not be listed in full. A key with no
+// SKUID stands for every SKU of its ProductID.
+type ProductErrors map[Product]error
+
+func (receiver ProductErrors) Error() string {
+	messages := make([]string, 0, len(receiver))
+	for _, product := range receiver.products() {
+		name := product.SKUName
+		if name == "" {
+			name = product.ProductID
+		}
+		messages = append(messages, fmt.Sprintf("<%s>: %v", name, receiver[product]))
+	}
+	return fmt.Sprintf("%d products failed: %s", len(receiver), strings.Join(messages, "; "))
+}
+
+// Is matches target against each product's error, so errors.Is sees through ProductErrors on any Go version.
+func (receiver ProductErrors) Is(target error) bool {
+	for _, product := range receiver.products() {
+		if errors.Is(receiver[product], target) {
+			return true
+		}
+	}
+	return false
+}
+
+func (receiver ProductErrors) As(target interface{}) bool {
+	for _, product := range receiver.products() {
+		if errors.As(receiver[product], target) {
+			return true
+		}
+	}
+	return false
+}
+
+func (receiver ProductErrors) products() []Product {
+	products := make([]Product, 0, len(receiver))
+	for product := range receiver {
+		products = append(products, product)
+	}
+	sort.Slice(products, func(i, j int) bool {
+		if products[i].ProductID != products[j].ProductID {
+			return products[i].ProductID < products[j].ProductID
+		}
+		return products[i].SKUID < products[j].SKUID
+	})
+	return products
+}
+
+// DefaultLicenseRoutines is how many products GetLicenses and GetLicensesMap list at once when maxRoutines is
+// zero or less.
+const DefaultLicenseRoutines = 4
+
+// GetLicenses lists the assignments of every product, at most maxRoutines products at a time. Products that
+// fail are reported in a ProductErrors while the assignments of the rest are still returned. A product given
+// more than once is listed once.
+func (receiver *Licensing3k) GetLicenses(products []Product, maxResults int64, maxRoutines int, ctx context.Context, fields ...googleapi.Field) ([]*licensing.LicenseAssignment, error) {
+	lists, err := receiver.listProducts("GetLicenses", products, maxResults, maxRoutines, ctx, fields...)
 	var licenseAssignments []*licensing.LicenseAssignment
-	wg := sync.WaitGroup{}
-	routineCount := len(products)
-	wg.Add(routineCount)
-	log.Printf("Running %d routines for GetLicenses()\n", routineCount)
-	for _, currentProduct := range products {
-		go func(product Product) {
-			defer wg.Done()
-			log.Printf("Querying for <%s> licenses...\n", product.SKUName)
-			currentSet := receiver.ListForProductAndSku(product.ProductID, product.SKUID, maxResults)
-			if currentSet != nil {
-				licenseAssignments = append(licenseAssignments, currentSet...)
-			}
-		}(currentProduct)
+	for _, list := range lists {
+		licenseAssignments = append(licenseAssignments, list.assignments...)
+	}
+	return licenseAssignments, err
+}
+
+// GetLicensesMap is GetLicenses keyed by product. A product that failed maps to what was listed before the error.
+func (receiver *Licensing3k) GetLicensesMap(products []Product, maxResults int64, maxRoutines int, ctx context.Context, fields ...googleapi.Field) (map[Product][]*licensing.LicenseAssignment, error) {
+	lists, err := receiver.listProducts("GetLicensesMap", products, maxResults, maxRoutines, ctx, fields...)
+	productAssignmentsMap := make(map[Product][]*licensing.LicenseAssignment, len(lists))
+	for _, list := range lists {
+		productAssignmentsMap[list.product] = list.assignments
 	}
-	wg.Wait()
-	return licenseAssignments
+	return productAssignmentsMap, err
 }
 
-func (receiver *Licensing3k) GetLicensesMap(products []Product, maxResults int64) map[Product][]*licensing.LicenseAssignment {
-	productAssignmentsMap := make(map[Product][]*licensing.LicenseAssignment)
-	wg := sync.WaitGroup{}
-	routineCount := len(products)
-	wg.Add(routineCount)
-	log.Printf("Running %d routines for GetLicensesMap()\n", routineCount)
+type productLicenses struct {
+	product     Product
+	assignments []*licensing.LicenseAssignment
+}
 
+func (receiver *Licensing3k) listProducts(op string, products []Product, maxResults int64, maxRoutines int, ctx context.Context, fields ...googleapi.Field) ([]*productLicenses, error) {
+	if maxRoutines <= 0 {
+		maxRoutines = DefaultLicenseRoutines
+	}
+	var lists []*productLicenses
+	seen := make(map[Product]bool)
 	for _, product := range products {
-		go func(product Product) {
-			defer wg.Done()
-			log.Printf("Querying for <%s> licenses...\n", product.SKUName)
-			currentSet := receiver.ListForProductAndSku(product.ProductID, product.SKUID, maxResults)
-			productAssignmentsMap[product] = currentSet
-		}(product)
+		if !seen[product] {
+			seen[product] = true
+			lists = append(lists, &productLicenses{product: product})
+		}
 	}
-	wg.Wait()
-	return productAssignmentsMap
+	receiver.logger().Printf("Running up to %d routines for %s()\n", maxRoutines, op)
+	results := runPool(lists, func(list *productLicenses) string { return list.product.SKUID }, maxRoutines, nil, ctx, func(list *productLicenses) (err error) {
+		receiver.logger().Printf("Querying for <%s> licenses...\n", list.product.SKUName)
+		list.assignments, err = receiver.ListForProductAndSku(list.product.ProductID, list.product.SKUID, maxResults, ctx, fields...)
+		return err
+	})
+	productErrors := make(ProductErrors)
+	for i, result := range results {
+		if result.Err != nil {
+			productErrors[lists[i].product] = result.Err
+		}
+	}
+	if len(productErrors) > 0 {
+		return lists, productErrors
+	}
+	return lists, nil
 }
 
-func (receiver *Licensing3k) Delete(product *Product, userID string) {
-	_, err := receiver.Service.LicenseAssignments.Delete(product.ProductID, product.SKUID, userID).Do()
+func (receiver *Licensing3k) Delete(product *Product, userID string, ctx context.Context) error {
+	err := receiver.retry("Delete", ctx, func() error {
+		_, err := receiver.Service.LicenseAssignments.Delete(product.ProductID, product.SKUID, userID).Context(ctx).Do()
+		return err
+	})
 	if err != nil {
-		log.Println(err.Error())
-		panic(err)
+		receiver.logger().Println(err.Error())
+		return err
 	}
+	return nil
 }
 
-func (receiver *Licensing3k) Get(product *Product, userID string) *licensing.LicenseAssignment {
-	response, err := receiver.Service.LicenseAssignments.Get(product.ProductID, product.SKUID, userID).Fields("*").Do()
+func (receiver *Licensing3k) Get(product *Product, userID string, ctx context.Context, fields ...googleapi.Field) (*licensing.LicenseAssignment, error) {
+	var response *licensing.LicenseAssignment
+	err := receiver.retry("Get", ctx, func() (err error) {
+		response, err = receiver.Service.LicenseAssignments.Get(product.ProductID, product.SKUID, userID).Fields(itemFields(fields)).Context(ctx).Do()
+		return err
+	})
 	if err != nil {
-		log.Println(err.Error())
-		panic(err)
+		receiver.logger().Println(err.Error())
+		return nil, err
 	}
-	return response
+	return response, nil
 }
 
-func (receiver *Licensing3k) Insert(product *Product, userID string) *licensing.LicenseAssignment {
+func (receiver *Licensing3k) Insert(product *Product, userID string, ctx context.Context) (*licensing.LicenseAssignment, error) {
 	licensingAssignmentInsert := &licensing.LicenseAssignmentInsert{}
 	licensingAssignmentInsert.UserId = userID
-	response, err := receiver.Service.LicenseAssignments.Insert(product.ProductID, product.SKUID, licensingAssignmentInsert).Do()
+	var response *licensing.LicenseAssignment
+	err := receiver.retry("Insert", ctx, func() (err error) {
+		response, err = receiver.Service.LicenseAssignments.Insert(product.ProductID, product.SKUID, licensingAssignmentInsert).Context(ctx).Do()
+		return err
+	})
 	if err != nil {
-		log.Println(err.Error())
-		panic(err)
+		receiver.logger().Println(err.Error())
+		return nil, err
 	}
-	return response
+	return response, nil
 }
 
-func (receiver *Licensing3k) ListForProduct(productID string, maxResults int64) []*licensing.LicenseAssignment {
+func (receiver *Licensing3k) ListForProduct(productID string, maxResults int64, ctx context.Context, fields ...googleapi.Field) ([]*licensing.LicenseAssignment, error) {
 	var licenseAssignments []*licensing.LicenseAssignment
 	pageToken := ""
 	skuName := ""
 	for {
-		response, err := receiver.Service.LicenseAssignments.
-			ListForProduct(productID, receiver.CustomerID).
-			Fields("*").
-			MaxResults(maxResults).
-			PageToken(pageToken).
-			Do()
-
+		var response *licensing.LicenseAssignmentList
+		err := receiver.retry("ListForProduct", ctx, func() (err error) {
+			response, err = receiver.Service.LicenseAssignments.
+				ListForProduct(productID, receiver.CustomerID).
+				Fields(listFields("items", fields)).
+				MaxResults(maxResults).
+				PageToken(pageToken).
+				Context(ctx).
+				Do()
+			return err
+		})
 		if err != nil {
-			if strings.Contains(err.Error(), "400") {
-				log.Println(err.Error())
-				return licenseAssignments
-			} else {
-				panic(err)
-			}
+			receiver.logger().Println(err.Error())
+			return licenseAssignments, err
+		}
+		if response.Items == nil || len(response.Items) == 0 {
+			receiver.logger().Printf("{%s} - No further licenses under %s\n", receiver.CustomerID, productID)
+			break
 		}
 		skuName = response.Items[0].SkuName
 		licenseAssignments = append(licenseAssignments, response.Items...)
@@ -168,39 +293,35 @@ func (receiver *Licensing3k) ListForProduct(productID string, maxResults int64)
 		if pageToken == "" {
 			break
 		}
-		if response.Items == nil || len(response.Items) == 0 {
-			log.Printf("{%s} - No further licenses under %s\n", receiver.CustomerID, productID)
-			break
-		}
-		log.Printf("SKUName: %s, ProductID: %s - licenses thus far: %d\n", skuName, productID, len(licenseAssignments))
+		receiver.logger().Printf("SKUName: %s, ProductID: %s - licenses thus far: %d\n", skuName, productID, len(licenseAssignments))
 	}
-	log.Printf("%s licenses Total: %d\n", skuName, len(licenseAssignments))
-	return licenseAssignments
+	receiver.logger().Printf("%s licenses Total: %d\n", skuName, len(licenseAssignments))
+	return licenseAssignments, nil
 }
 
-func (receiver *Licensing3k) ListForProductAndSku(productID, skuID string, maxResults int64) []*licensing.LicenseAssignment {
+func (receiver *Licensing3k) ListForProductAndSku(productID, skuID string, maxResults int64, ctx context.Context, fields ...googleapi.Field) ([]*licensing.LicenseAssignment, error) {
 	var licenseAssignments []*licensing.LicenseAssignment
 	pageToken := ""
 	skuName := ""
 
 	for {
-		response, err := receiver.Service.LicenseAssignments.
-			ListForProductAndSku(productID, skuID, receiver.CustomerID).
-			Fields("*").
-			MaxResults(maxResults).
-			PageToken(pageToken).
-			Do()
-
+		var response *licensing.LicenseAssignmentList
+		err := receiver.retry("ListForProductAndSku", ctx, func() (err error) {
+			response, err = receiver.Service.LicenseAssignments.
+				ListForProductAndSku(productID, skuID, receiver.CustomerID).
+				Fields(listFields("items", fields)).
+				MaxResults(maxResults).
+				PageToken(pageToken).
+				Context(ctx).
+				Do()
+			return err
+		})
 		if err != nil {
-			if strings.Contains(err.Error(), "400") {
-				log.Println(err.Error())
-				return licenseAssignments
-			} else {
-				panic(err)
-			}
+			receiver.logger().Println(err.Error())
+			return licenseAssignments, err
 		}
 		if response.Items == nil || len(response.Items) == 0 {
-			log.Printf("{%s} - No further licenses under %s -- %s\n", receiver.CustomerID, skuID, productID)
+			receiver.logger().Printf("{%s} - No further licenses under %s -- %s\n", receiver.CustomerID, skuID, productID)
 			break
 		}
 		skuName = response.Items[0].SkuName
@@ -209,54 +330,73 @@ func (receiver *Licensing3k) ListForProductAndSku(productID, skuID string, maxRe
 		if pageToken == "" {
 			break
 		}
-		log.Printf("SKUName: %s, SKUID: %s, ProductID: %s - licenses thus far: %d\n", skuName, skuID, productID, len(licenseAssignments))
+		receiver.logger().Printf("SKUName: %s, SKUID: %s, ProductID: %s - licenses thus far: %d\n", skuName, skuID, productID, len(licenseAssignments))
 	}
 
-	log.Printf("%s licenses Total: %d\n", skuName, len(licenseAssignments))
-	return licenseAssignments
+	receiver.logger().Printf("%s licenses Total: %d\n", skuName, len(licenseAssignments))
+	return licenseAssignments, nil
 }
 
-func (receiver *Licensing3k) Update(productID, skuID, userID string) *licensing.LicenseAssignment {
+// Update writes the assignment back under the same SKU. Use SwapSKU to move a user to another SKU.
+func (receiver *Licensing3k) Update(productID, skuID, userID string, ctx context.Context) (*licensing.LicenseAssignment, error) {
 	newLicenseAssignment := &licensing.LicenseAssignment{
 		ProductId: productID,
 		SkuId:     skuID,
 		UserId:    userID,
 	}
 
-	response, err := receiver.Service.LicenseAssignments.Update(productID, skuID, userID, newLicenseAssignment).Fields("*").Do()
+	var response *licensing.LicenseAssignment
+	err := receiver.retry("Update", ctx, func() (err error) {
+		response, err = receiver.Service.LicenseAssignments.Update(productID, skuID, userID, newLicenseAssignment).Fields("*").Context(ctx).Do()
+		return err
+	})
 	if err != nil {
-		log.Println(err.Error())
-		panic(err)
+		receiver.logger().Println(err.Error())
+		return nil, err
+	}
+	return response, nil
+}
+
+// SwapSKU moves userID from one SKU of a product to another in a single call, so the user is never left unlicensed.
+func (receiver *Licensing3k) SwapSKU(userID string, from, to *Product, ctx context.Context) (*licensing.LicenseAssignment, error) {
+	if from.ProductID != to.ProductID {
+		err := invalidArgument("SwapSKU", "cannot swap <%s> for <%s>, they belong to different products", from.SKUName, to.SKUName)
+		receiver.logger().Println(err.Error())
+		return nil, err
+	}
+	var response *licensing.LicenseAssignment
+	err := receiver.retry("SwapSKU", ctx, func() (err error) {
+		response, err = receiver.Service.LicenseAssignments.Update(from.ProductID, from.SKUID, userID, &licensing.LicenseAssignment{SkuId: to.SKUID}).Context(ctx).Do()
+		return err
+	})
+	if err != nil {
+		receiver.logger().Println(err.Error())
+		return nil, err
 	}
-	return response
+	receiver.logger().Printf("(%s) moved from <%s> to <%s>\n", userID, from.SKUName, to.SKUName)
+	return response, nil
 }
 
 /*Licensing Product Custom Type*/
 type Product struct {
-	ProductID           string
-	ProductName         string
-	SKUID               string
-	SKUName             string
-	UnarchivalProductID string
-	UnarchivalSKUID     string
+	ProductID           string `json:"productId"`
+	ProductName         string `json:"productName"`
+	SKUID               string `json:"skuId"`
+	SKUName             string `json:"skuName"`
+	UnarchivalProductID string `json:"unarchivalProductId,omitempty"`
+	UnarchivalSKUID     string `json:"unarchivalSkuId,omitempty"`
 }
 
+// GetProductBySKUID returns an empty Product for an unknown SKU. Use DefaultCatalog.BySKUID to tell the two apart.
 func GetProductBySKUID(skuID string) Product {
-	for _, product := range AllProducts {
-		if product.SKUID == skuID {
-			return product
-		}
-	}
-	return Product{}
+	product, _ := DefaultCatalog.BySKUID(skuID)
+	return product
 }
 
+// GetProductByName returns an empty Product for an unknown SKU. Use DefaultCatalog.ByName to tell the two apart.
 func GetProductByName(skuName string) Product {
-	for _, product := range AllProducts {
-		if product.SKUName == skuName {
-			return product
-		}
-	}
-	return Product{}
+	product, _ := DefaultCatalog.ByName(skuName)
+	return product
 }
 
 var GoogleWorkspaceBusinessStarter = Product{
@@ -0,0 +1,73 @@
+package googleadmin3k
+
+import (
+	admin "google.golang.org/api/admin/directory/v1"
+	"google.golang.org/api/googleapi"
+	"strings"
+)
+
+// MemberFilter narrows a member listing. Empty criteria match everything, values are case-insensitive.
+// Types are USER, GROUP, CUSTOMER or EXTERNAL; DeliverySettings are ALL_MAIL, DAILY, DIGEST, DISABLED or NONE.
+type MemberFilter struct {
+	Roles                    []string
+	Types                    []string
+	Statuses                 []string
+	DeliverySettings         []string
+	IncludeDerivedMembership bool
+}
+
+func (receiver *MemberFilter) Matches(member *admin.Member) bool {
+	if receiver == nil {
+		return true
+	}
+	return matchesAny(member.Role, receiver.Roles) &&
+		matchesAny(member.Type, receiver.Types) &&
+		matchesAny(member.Status, receiver.Statuses) &&
+		matchesAny(member.DeliverySettings, receiver.DeliverySettings)
+}
+
+func (receiver *MemberFilter) apply(members []*admin.Member) []*admin.Member {
+	if receiver == nil {
+		return members
+	}
+	filtered := make([]*admin.Member, 0, len(members))
+	for _, member := range members {
+		if receiver.Matches(member) {
+			filtered = append(filtered, member)
+		}
+	}
+	return filtered
+}
+
+// requiredFields adds the member fields the client-side criteria need to an explicit selection.
+func (receiver *MemberFilter) requiredFields(fields []googleapi.Field) []googleapi.Field {
+	if receiver == nil || len(fields) == 0 {
+		return fields
+	}
+	fields = append([]googleapi.Field{}, fields...)
+	if len(receiver.Roles) > 0 {
+		fields = append(fields, "role")
+	}
+	if len(receiver.Types) > 0 {
+		fields = append(fields, "type")
+	}
+	if len(receiver.Statuses) > 0 {
+		fields = append(fields, "status")
+	}
+	if len(receiver.DeliverySettings) > 0 {
+		fields = append(fields, "delivery_settings")
+	}
+	return fields
+}
+
+func matchesAny(value string, allowed []string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+	for _, candidate := range allowed {
+		if strings.EqualFold(value, candidate) {
+			return true
+		}
+	}
+	return false
+}
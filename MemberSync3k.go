@@ -0,0 +1,177 @@
+package googleadmin3k
+
+import (
+	"context"
+	admin "google.golang.org/api/admin/directory/v1"
+	"strings"
+)
+
+/*Member change actions*/
+const (
+	MemberAdd    = "ADD"
+	MemberRemove = "REMOVE"
+	MemberUpdate = "UPDATE"
+)
+
+type SyncOptions struct {
+	DryRun bool
+	// Owners missing from the desired list, or desired with a lower role, are left alone unless set.
+	AllowOwnerChanges bool
+	MaxRoutines       int
+}
+
+type MemberChange struct {
+	Action string
+	Email  string
+	Before *admin.Member
+	After  *admin.Member
+	Err    error
+}
+
+type SyncReport struct {
+	GroupEmail string
+	DryRun     bool
+	Changes    []*MemberChange
+	Protected  []*admin.Member
+	Unchanged  int
+}
+
+func (receiver *SyncReport) Count(action string) int {
+	count := 0
+	for _, change := range receiver.Changes {
+		if change.Action == action {
+			count++
+		}
+	}
+	return count
+}
+
+func (receiver *SyncReport) Failed() []*MemberChange {
+	var failed []*MemberChange
+	for _, change := range receiver.Changes {
+		if change.Err != nil {
+			failed = append(failed, change)
+		}
+	}
+	return failed
+}
+
+// SyncGroupMembers reconciles the membership of groupEmail with desired. A desired member with an empty
+// Role is a MEMBER, and an empty DeliverySettings keeps whatever the current member has.
+func (receiver *Directory3k) SyncGroupMembers(groupEmail string, desired []*admin.Member, options *SyncOptions, ctx context.Context) (*SyncReport, error) {
+	if options == nil {
+		options = &SyncOptions{}
+	}
+	current, err := receiver.GetAllMembers(groupEmail, ctx, MemberFieldsBasic)
+	if err != nil {
+		return nil, err
+	}
+	report := &SyncReport{GroupEmail: groupEmail, DryRun: options.DryRun}
+	report.Changes, report.Protected, report.Unchanged = planMemberChanges(current, desired, options.AllowOwnerChanges)
+	receiver.logger().Printf("Sync of %s: %d to add, %d to remove, %d to update, %d protected owners, %d unchanged\n",
+		groupEmail, report.Count(MemberAdd), report.Count(MemberRemove), report.Count(MemberUpdate), len(report.Protected), report.Unchanged)
+	if options.DryRun {
+		return report, nil
+	}
+
+	results := runPool(report.Changes, func(change *MemberChange) string { return change.Email }, options.MaxRoutines, nil, ctx, func(change *MemberChange) error {
+		return receiver.applyMemberChange(groupEmail, change, ctx)
+	})
+	for i, result := range results {
+		report.Changes[i].Err = result.Err
+	}
+
+	if failed := report.Failed(); len(failed) > 0 {
+		return report, failed[0].Err
+	}
+	return report, nil
+}
+
+func (receiver *Directory3k) applyMemberChange(groupEmail string, change *MemberChange, ctx context.Context) error {
+	switch change.Action {
+	case MemberAdd:
+		_, err := receiver.PushMember(groupEmail, change.After, ctx)
+		return err
+	case MemberRemove:
+		return receiver.DeleteMember(groupEmail, memberKey(change.Before), ctx)
+	case MemberUpdate:
+		patch := &admin.Member{Role: change.After.Role, DeliverySettings: change.After.DeliverySettings}
+		err := receiver.retry("SyncGroupMembers", ctx, func() error {
+			_, err := receiver.Service.Members.Patch(groupEmail, memberKey(change.Before), patch).Context(ctx).Do()
+			return err
+		})
+		if err != nil {
+			receiver.logger().Println(err.Error())
+			return err
+		}
+		receiver.logger().Printf("Update of [%s] in (%s) to %s was successful!", change.Email, groupEmail, change.After.Role)
+	}
+	return nil
+}
+
+func planMemberChanges(current, desired []*admin.Member, allowOwnerChanges bool) ([]*MemberChange, []*admin.Member, int) {
+	var changes []*MemberChange
+	var protected []*admin.Member
+	unchanged := 0
+
+	currentByKey := make(map[string]*admin.Member)
+	for _, member := range current {
+		currentByKey[strings.ToLower(memberKey(member))] = member
+	}
+	desiredKeys := make(map[string]bool)
+	for _, want := range desired {
+		key := strings.ToLower(memberKey(want))
+		if key == "" || desiredKeys[key] {
+			continue
+		}
+		desiredKeys[key] = true
+		role := strings.ToUpper(want.Role)
+		if role == "" {
+			role = "MEMBER"
+		}
+
+		have, exists := currentByKey[key]
+		if !exists {
+			after := *want
+			after.Role = role
+			changes = append(changes, &MemberChange{Action: MemberAdd, Email: memberKey(want), After: &after})
+			continue
+		}
+		deliverySettings := want.DeliverySettings
+		if deliverySettings == "" {
+			deliverySettings = have.DeliverySettings
+		}
+		if have.Role == role && have.DeliverySettings == deliverySettings {
+			unchanged++
+			continue
+		}
+		if have.Role == "OWNER" && role != "OWNER" && !allowOwnerChanges {
+			protected = append(protected, have)
+			continue
+		}
+		after := *have
+		after.Role = role
+		after.DeliverySettings = deliverySettings
+		changes = append(changes, &MemberChange{Action: MemberUpdate, Email: memberKey(have), Before: have, After: &after})
+	}
+
+	for _, have := range current {
+		if desiredKeys[strings.ToLower(memberKey(have))] {
+			continue
+		}
+		if have.Role == "OWNER" && !allowOwnerChanges {
+			protected = append(protected, have)
+			continue
+		}
+		changes = append(changes, &MemberChange{Action: MemberRemove, Email: memberKey(have), Before: have})
+	}
+	return changes, protected, unchanged
+}
+
+// memberKey is the email of a member, or its ID for members such as CUSTOMER that have none.
+func memberKey(member *admin.Member) string {
+	if member.Email != "" {
+		return member.Email
+	}
+	return member.Id
+}
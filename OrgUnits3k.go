@@ -0,0 +1,205 @@
+package googleadmin3k
+
+import (
+	"context"
+	"encoding/json"
+	admin "google.golang.org/api/admin/directory/v1"
+	"google.golang.org/api/googleapi"
+	"sort"
+	"strings"
+)
+
+/*Org unit methods*/
+
+// ListOrgUnits returns every org unit below the root, the root itself is not included.
+func (receiver *Directory3k) ListOrgUnits(ctx context.Context, fields ...googleapi.Field) ([]*admin.OrgUnit, error) {
+	selection := googleapi.Field("*")
+	if len(fields) > 0 {
+		selection = googleapi.Field("organizationUnits(" + googleapi.CombineFields(fields) + ")")
+	}
+	var response *admin.OrgUnits
+	err := receiver.retry("ListOrgUnits", ctx, func() (err error) {
+		response, err = receiver.Service.Orgunits.List(receiver.CustomerID).Type("all").Fields(selection).Context(ctx).Do()
+		return err
+	})
+	if err != nil {
+		receiver.logger().Println(err.Error())
+		return nil, err
+	}
+	receiver.logger().Printf("Listed %d org units\n", len(response.OrganizationUnits))
+	return response.OrganizationUnits, nil
+}
+
+// GetOrgUnit takes the full path of the org unit, or its ID prefixed with "id:".
+func (receiver *Directory3k) GetOrgUnit(orgUnitPath string, ctx context.Context, fields ...googleapi.Field) (*admin.OrgUnit, error) {
+	var response *admin.OrgUnit
+	err := receiver.retry("GetOrgUnit", ctx, func() (err error) {
+		response, err = receiver.Service.Orgunits.Get(receiver.CustomerID, orgUnitKey(orgUnitPath)).Fields(itemFields(fields)).Context(ctx).Do()
+		return err
+	})
+	if err != nil {
+		receiver.logger().Println(err.Error())
+		return nil, err
+	}
+	return response, nil
+}
+
+// CreateOrgUnit needs the Name and ParentOrgUnitPath (or ParentOrgUnitId) of the new org unit.
+func (receiver *Directory3k) CreateOrgUnit(orgUnit *admin.OrgUnit, ctx context.Context) (*admin.OrgUnit, error) {
+	if orgUnit == nil || orgUnit.Name == "" {
+		return nil, invalidArgument("CreateOrgUnit", "org unit has no name")
+	}
+	if orgUnit.ParentOrgUnitPath == "" && orgUnit.ParentOrgUnitId == "" {
+		return nil, invalidArgument("CreateOrgUnit", "org unit %q has no parent", orgUnit.Name)
+	}
+	var response *admin.OrgUnit
+	err := receiver.retry("CreateOrgUnit", ctx, func() (err error) {
+		response, err = receiver.Service.Orgunits.Insert(receiver.CustomerID, orgUnit).Context(ctx).Do()
+		return err
+	})
+	if err != nil {
+		receiver.logger().Println(err.Error())
+		return nil, err
+	}
+	receiver.logger().Printf("Org unit [%s] created\n", response.OrgUnitPath)
+	return response, nil
+}
+
+// UpdateOrgUnit replaces the org unit resource, fields left empty are cleared. Use PatchOrgUnit to change only some fields.
+func (receiver *Directory3k) UpdateOrgUnit(orgUnitPath string, orgUnit *admin.OrgUnit, ctx context.Context) (*admin.OrgUnit, error) {
+	var response *admin.OrgUnit
+	err := receiver.retry("UpdateOrgUnit", ctx, func() (err error) {
+		response, err = receiver.Service.Orgunits.Update(receiver.CustomerID, orgUnitKey(orgUnitPath), orgUnit).Context(ctx).Do()
+		return err
+	})
+	if err != nil {
+		receiver.logger().Println(err.Error())
+		return nil, err
+	}
+	receiver.logger().Printf("Org unit [%s] updated\n", orgUnitPath)
+	return response, nil
+}
+
+func (receiver *Directory3k) PatchOrgUnit(orgUnitPath string, orgUnit *admin.OrgUnit, ctx context.Context) (*admin.OrgUnit, error) {
+	var response *admin.OrgUnit
+	err := receiver.retry("PatchOrgUnit", ctx, func() (err error) {
+		response, err = receiver.Service.Orgunits.Patch(receiver.CustomerID, orgUnitKey(orgUnitPath), orgUnit).Context(ctx).Do()
+		return err
+	})
+	if err != nil {
+		receiver.logger().Println(err.Error())
+		return nil, err
+	}
+	receiver.logger().Printf("Org unit [%s] patched\n", orgUnitPath)
+	return response, nil
+}
+
+// MoveOrgUnit places the org unit, with everything below it, under parentOrgUnitPath.
+func (receiver *Directory3k) MoveOrgUnit(orgUnitPath, parentOrgUnitPath string, ctx context.Context) (*admin.OrgUnit, error) {
+	return receiver.PatchOrgUnit(orgUnitPath, &admin.OrgUnit{ParentOrgUnitPath: parentOrgUnitPath}, ctx)
+}
+
+// DeleteOrgUnit fails while the org unit still holds users or child org units.
+func (receiver *Directory3k) DeleteOrgUnit(orgUnitPath string, ctx context.Context) error {
+	err := receiver.retry("DeleteOrgUnit", ctx, func() error {
+		return receiver.Service.Orgunits.Delete(receiver.CustomerID, orgUnitKey(orgUnitPath)).Context(ctx).Do()
+	})
+	if err != nil {
+		receiver.logger().Println(err.Error())
+		return err
+	}
+	receiver.logger().Printf("Org unit [%s] deleted\n", orgUnitPath)
+	return nil
+}
+
+func (receiver *Directory3k) MoveUsersToOrgUnit(userKeys []string, orgUnitPath string, maxRoutines int, ctx context.Context) ([]*BulkResult, error) {
+	results := runPool(userKeys, func(userKey string) string { return userKey }, maxRoutines, nil, ctx, func(userKey string) error {
+		_, err := receiver.MoveUser(userKey, orgUnitPath, ctx)
+		return err
+	})
+	receiver.logger().Printf("Move of %d users to %s: %d succeeded, %d failed\n", len(userKeys), orgUnitPath,
+		countBulkResults(results, BulkSucceeded), countBulkResults(results, BulkFailed))
+	return results, firstBulkError(results)
+}
+
+// orgUnitKey drops the leading slash the API does not accept in the orgUnitPath parameter.
+func orgUnitKey(orgUnitPath string) string {
+	return strings.TrimPrefix(orgUnitPath, "/")
+}
+
+/*Org unit tree*/
+type OrgUnitNode struct {
+	Name        string         `json:"name"`
+	Path        string         `json:"path"`
+	ID          string         `json:"id,omitempty"`
+	Description string         `json:"description,omitempty"`
+	Children    []*OrgUnitNode `json:"children,omitempty"`
+}
+
+func (receiver *Directory3k) GetOrgUnitTree(ctx context.Context) (*OrgUnitNode, error) {
+	orgUnits, err := receiver.ListOrgUnits(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return NewOrgUnitTree(orgUnits), nil
+}
+
+// NewOrgUnitTree arranges orgUnits under a root "/" node, children sorted by name. Org units whose parent is
+// missing from the list are placed under the root.
+func NewOrgUnitTree(orgUnits []*admin.OrgUnit) *OrgUnitNode {
+	root := &OrgUnitNode{Name: "/", Path: "/"}
+	nodes := map[string]*OrgUnitNode{"/": root}
+	for _, orgUnit := range orgUnits {
+		nodes[strings.ToLower(orgUnit.OrgUnitPath)] = &OrgUnitNode{
+			Name:        orgUnit.Name,
+			Path:        orgUnit.OrgUnitPath,
+			ID:          orgUnit.OrgUnitId,
+			Description: orgUnit.Description,
+		}
+	}
+	for _, orgUnit := range orgUnits {
+		parent, ok := nodes[strings.ToLower(orgUnit.ParentOrgUnitPath)]
+		if !ok {
+			parent = root
+		}
+		if orgUnit.ParentOrgUnitPath == "/" && root.ID == "" {
+			root.ID = orgUnit.ParentOrgUnitId
+		}
+		parent.Children = append(parent.Children, nodes[strings.ToLower(orgUnit.OrgUnitPath)])
+	}
+	for _, node := range nodes {
+		sort.Slice(node.Children, func(i, j int) bool { return node.Children[i].Name < node.Children[j].Name })
+	}
+	return root
+}
+
+// Find returns the node at orgUnitPath, nil when it is not in the tree.
+func (receiver *OrgUnitNode) Find(orgUnitPath string) *OrgUnitNode {
+	if strings.EqualFold(receiver.Path, orgUnitPath) {
+		return receiver
+	}
+	for _, child := range receiver.Children {
+		if found := child.Find(orgUnitPath); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// String draws the tree one org unit per line, indented by depth.
+func (receiver *OrgUnitNode) String() string {
+	builder := &strings.Builder{}
+	var write func(*OrgUnitNode, int)
+	write = func(node *OrgUnitNode, depth int) {
+		builder.WriteString(strings.Repeat("  ", depth) + node.Name + "\n")
+		for _, child := range node.Children {
+			write(child, depth+1)
+		}
+	}
+	write(receiver, 0)
+	return builder.String()
+}
+
+func (receiver *OrgUnitNode) JSON() ([]byte, error) {
+	return json.MarshalIndent(receiver, "", "  ")
+}
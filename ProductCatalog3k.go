@@ -0,0 +1,206 @@
+package googleadmin3k
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"google.golang.org/api/licensing/v1"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Catalog files are JSON arrays of Product. YAML is not supported, the module has no YAML dependency.
+//
+//go:embed products.json
+var embeddedProducts []byte
+
+// DefaultCatalog holds the SKUs shipped in products.json. Load override files into it, or into a catalog of
+// your own, to pick up SKUs without a new release.
+var DefaultCatalog = mustProductCatalog(embeddedProducts)
+
+// ProductCatalog is a set of SKUs keyed by SKUID, safe for concurrent use.
+type ProductCatalog struct {
+	mutex    sync.RWMutex
+	products []Product
+	bySKUID  map[string]int
+}
+
+func NewProductCatalog(products []Product) *ProductCatalog {
+	catalog := &ProductCatalog{bySKUID: make(map[string]int)}
+	for _, product := range products {
+		catalog.put(product)
+	}
+	return catalog
+}
+
+// LoadProductCatalog starts from the embedded SKUs and applies each override file in order.
+func LoadProductCatalog(overridePaths ...string) (*ProductCatalog, error) {
+	catalog := mustProductCatalog(embeddedProducts)
+	for _, path := range overridePaths {
+		if err := catalog.LoadFile(path); err != nil {
+			return nil, err
+		}
+	}
+	return catalog, nil
+}
+
+func mustProductCatalog(data []byte) *ProductCatalog {
+	catalog := NewProductCatalog(nil)
+	if err := catalog.Merge(data); err != nil {
+		panic(err)
+	}
+	return catalog
+}
+
+func (receiver *ProductCatalog) LoadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return wrapError("LoadProductCatalog", err)
+	}
+	if err = receiver.Merge(data); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+// Merge adds the products in data, replacing those already in the catalog with the same SKUID.
+func (receiver *ProductCatalog) Merge(data []byte) error {
+	var products []Product
+	if err := json.Unmarshal(data, &products); err != nil {
+		return invalidArgument("ProductCatalog", "%v", err)
+	}
+	for i, product := range products {
+		if product.ProductID == "" || product.SKUID == "" {
+			return invalidArgument("ProductCatalog", "product %d has no productId or skuId", i)
+		}
+	}
+	receiver.mutex.Lock()
+	defer receiver.mutex.Unlock()
+	for _, product := range products {
+		receiver.put(product)
+	}
+	return nil
+}
+
+func (receiver *ProductCatalog) put(product Product) {
+	if index, exists := receiver.bySKUID[product.SKUID]; exists {
+		receiver.products[index] = product
+		return
+	}
+	receiver.bySKUID[product.SKUID] = len(receiver.products)
+	receiver.products = append(receiver.products, product)
+}
+
+// Add puts product in the catalog unless its SKUID is already known, and reports whether it was added.
+func (receiver *ProductCatalog) Add(product Product) bool {
+	receiver.mutex.Lock()
+	defer receiver.mutex.Unlock()
+	if _, exists := receiver.bySKUID[product.SKUID]; exists {
+		return false
+	}
+	receiver.put(product)
+	return true
+}
+
+// Products returns every SKU in the order they were added.
+func (receiver *ProductCatalog) Products() []Product {
+	receiver.mutex.RLock()
+	defer receiver.mutex.RUnlock()
+	return append([]Product{}, receiver.products...)
+}
+
+func (receiver *ProductCatalog) BySKUID(skuID string) (Product, bool) {
+	receiver.mutex.RLock()
+	defer receiver.mutex.RUnlock()
+	index, exists := receiver.bySKUID[skuID]
+	if !exists {
+		return Product{}, false
+	}
+	return receiver.products[index], true
+}
+
+// ByName matches the SKU name regardless of case.
+func (receiver *ProductCatalog) ByName(skuName string) (Product, bool) {
+	receiver.mutex.RLock()
+	defer receiver.mutex.RUnlock()
+	for _, product := range receiver.products {
+		if strings.EqualFold(product.SKUName, skuName) {
+			return product, true
+		}
+	}
+	return Product{}, false
+}
+
+// ByProductID returns every SKU of the product.
+func (receiver *ProductCatalog) ByProductID(productID string) ([]Product, bool) {
+	receiver.mutex.RLock()
+	defer receiver.mutex.RUnlock()
+	var products []Product
+	for _, product := range receiver.products {
+		if product.ProductID == productID {
+			products = append(products, product)
+		}
+	}
+	return products, len(products) > 0
+}
+
+// Lookup takes a SKU ID or a SKU name.
+func (receiver *ProductCatalog) Lookup(sku string) (Product, bool) {
+	if product, ok := receiver.BySKUID(sku); ok {
+		return product, true
+	}
+	return receiver.ByName(sku)
+}
+
+// Discover adds the SKUs of assignments the catalog does not know yet and returns them.
+func (receiver *ProductCatalog) Discover(assignments []*licensing.LicenseAssignment) []Product {
+	var discovered []Product
+	for _, assignment := range assignments {
+		if assignment.SkuId == "" || assignment.ProductId == "" {
+			continue
+		}
+		product := Product{
+			ProductID:   assignment.ProductId,
+			ProductName: assignment.ProductName,
+			SKUID:       assignment.SkuId,
+			SKUName:     assignment.SkuName,
+		}
+		if receiver.Add(product) {
+			discovered = append(discovered, product)
+		}
+	}
+	return discovered
+}
+
+// JSON exports the catalog in the format Merge and LoadFile read, for example to save discovered SKUs.
+func (receiver *ProductCatalog) JSON() ([]byte, error) {
+	return json.MarshalIndent(receiver.Products(), "", "  ")
+}
+
+// DiscoverProducts lists the assignments of every product in catalog and adds the SKUs it has not seen,
+// such as ones Google introduced after the catalog was written. Products the customer does not subscribe to
+// fail without stopping the others; their errors come back in a ProductErrors alongside what was discovered.
+func (receiver *Licensing3k) DiscoverProducts(catalog *ProductCatalog, maxResults int64, ctx context.Context) ([]Product, error) {
+	productNames := make(map[string]string)
+	for _, product := range catalog.Products() {
+		productNames[product.ProductID] = product.ProductName
+	}
+	var discovered []Product
+	productErrors := make(ProductErrors)
+	for _, productID := range sortedKeys(productNames) {
+		assignments, err := receiver.ListForProduct(productID, maxResults, ctx, "productId", "productName", "skuId", "skuName")
+		discovered = append(discovered, catalog.Discover(assignments)...)
+		if err != nil {
+			productErrors[Product{ProductID: productID, ProductName: productNames[productID]}] = err
+		}
+	}
+	for _, product := range discovered {
+		receiver.logger().Printf("Discovered SKU <%s> (%s) of %s\n", product.SKUName, product.SKUID, product.ProductID)
+	}
+	if len(productErrors) > 0 {
+		return discovered, productErrors
+	}
+	return discovered, nil
+}
@@ -0,0 +1,154 @@
+package googleadmin3k
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// RateLimiter is a token bucket shared by every goroutine that holds it.
+type RateLimiter struct {
+	mutex    sync.Mutex
+	rate     float64
+	burst    float64
+	tokens   float64
+	last     time.Time
+	requests int64
+	waits    int64
+	waitTime time.Duration
+}
+
+// RateLimiterStats counts a wait only once it ran to the end, so waits cut short by ctx are left out.
+type RateLimiterStats struct {
+	Requests int64
+	Waits    int64
+	WaitTime time.Duration
+}
+
+// NewRateLimiter allows perSecond calls on average and up to burst at once. A perSecond of zero or less does not limit.
+func NewRateLimiter(perSecond float64, burst int) *RateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &RateLimiter{rate: perSecond, burst: float64(burst), tokens: float64(burst), last: time.Now()}
+}
+
+func (receiver *RateLimiter) Wait(ctx context.Context) error {
+	return receiver.WaitN(1, ctx)
+}
+
+// WaitN blocks until n tokens are available or ctx is done, in which case the tokens are given back.
+func (receiver *RateLimiter) WaitN(n int, ctx context.Context) error {
+	if receiver.rate <= 0 {
+		return nil
+	}
+	receiver.mutex.Lock()
+	now := time.Now()
+	receiver.tokens += now.Sub(receiver.last).Seconds() * receiver.rate
+	if receiver.tokens > receiver.burst {
+		receiver.tokens = receiver.burst
+	}
+	receiver.last = now
+	receiver.tokens -= float64(n)
+	receiver.requests++
+	var delay time.Duration
+	if receiver.tokens < 0 {
+		delay = time.Duration(-receiver.tokens / receiver.rate * float64(time.Second))
+	}
+	receiver.mutex.Unlock()
+	if delay == 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		receiver.mutex.Lock()
+		receiver.waits++
+		receiver.waitTime += delay
+		receiver.mutex.Unlock()
+		return nil
+	case <-ctx.Done():
+		receiver.mutex.Lock()
+		receiver.tokens += float64(n)
+		receiver.mutex.Unlock()
+		return ctx.Err()
+	}
+}
+
+func (receiver *RateLimiter) Stats() RateLimiterStats {
+	receiver.mutex.Lock()
+	defer receiver.mutex.Unlock()
+	return RateLimiterStats{Requests: receiver.requests, Waits: receiver.waits, WaitTime: receiver.waitTime}
+}
+
+// RateLimits splits a service's calls into reads (GET) and writes (everything else). A nil limiter does not limit.
+type RateLimits struct {
+	Reads  *RateLimiter
+	Writes *RateLimiter
+}
+
+type ServiceRateLimits struct {
+	Directory       *RateLimits
+	Licensing       *RateLimits
+	GroupsMigration *RateLimits
+	GroupSettings   *RateLimits
+}
+
+// DefaultServiceRateLimits stays well below the default per-project quotas of each API.
+func DefaultServiceRateLimits() ServiceRateLimits {
+	return ServiceRateLimits{
+		Directory:       &RateLimits{Reads: NewRateLimiter(20, 20), Writes: NewRateLimiter(10, 10)},
+		Licensing:       &RateLimits{Reads: NewRateLimiter(5, 5), Writes: NewRateLimiter(5, 5)},
+		GroupsMigration: &RateLimits{Writes: NewRateLimiter(10, 10)},
+		GroupSettings:   &RateLimits{Reads: NewRateLimiter(5, 5), Writes: NewRateLimiter(5, 5)},
+	}
+}
+
+// RateLimitedClient returns a copy of client whose requests wait on limits first.
+func RateLimitedClient(client *http.Client, limits *RateLimits) *http.Client {
+	if limits == nil {
+		return client
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+	base := client.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	limited := *client
+	limited.Transport = &rateLimitedTransport{base: base, limits: limits}
+	return &limited
+}
+
+type rateLimitWeightKey struct{}
+
+// withRateLimitWeight makes a request count as n calls, used for batch requests.
+func withRateLimitWeight(ctx context.Context, n int) context.Context {
+	return context.WithValue(ctx, rateLimitWeightKey{}, n)
+}
+
+type rateLimitedTransport struct {
+	base   http.RoundTripper
+	limits *RateLimits
+}
+
+func (receiver *rateLimitedTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	limiter := receiver.limits.Writes
+	if request.Method == http.MethodGet || request.Method == http.MethodHead {
+		limiter = receiver.limits.Reads
+	}
+	if limiter != nil {
+		weight, ok := request.Context().Value(rateLimitWeightKey{}).(int)
+		if !ok || weight < 1 {
+			weight = 1
+		}
+		if err := limiter.WaitN(weight, request.Context()); err != nil {
+			return nil, err
+		}
+	}
+	return receiver.base.RoundTrip(request)
+}
@@ -0,0 +1,126 @@
+package googleadmin3k
+
+import (
+	"context"
+	"errors"
+	"google.golang.org/api/googleapi"
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy decides whether a failed call is attempted again and how long to wait first.
+// attempt starts at 1 and elapsed is the time since the first attempt began.
+type RetryPolicy interface {
+	Backoff(attempt int, elapsed time.Duration, err error) (time.Duration, bool)
+}
+
+// ExponentialBackoff retries quota and transient errors (403 rate limits, 429, 5xx)
+// with exponential backoff and jitter, honoring Retry-After when the server sends one.
+type ExponentialBackoff struct {
+	MaxAttempts    int
+	MaxElapsed     time.Duration
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	Multiplier     float64
+	Jitter         float64
+	Retryable      func(error) bool
+}
+
+var DefaultRetryPolicy RetryPolicy = &ExponentialBackoff{
+	MaxAttempts:    8,
+	MaxElapsed:     5 * time.Minute,
+	InitialBackoff: time.Second,
+	MaxBackoff:     64 * time.Second,
+	Multiplier:     2,
+	Jitter:         0.5,
+}
+
+var NoRetry RetryPolicy = &ExponentialBackoff{MaxAttempts: 1}
+
+func IsRetryable(err error) bool {
+	return errors.Is(err, ErrQuotaExceeded) || errors.Is(err, ErrTransient)
+}
+
+func (receiver *ExponentialBackoff) Backoff(attempt int, elapsed time.Duration, err error) (time.Duration, bool) {
+	retryable := receiver.Retryable
+	if retryable == nil {
+		retryable = IsRetryable
+	}
+	if !retryable(err) {
+		return 0, false
+	}
+	if receiver.MaxAttempts > 0 && attempt >= receiver.MaxAttempts {
+		return 0, false
+	}
+
+	multiplier := receiver.Multiplier
+	if multiplier < 1 {
+		multiplier = 1
+	}
+	wait := float64(receiver.InitialBackoff) * math.Pow(multiplier, float64(attempt-1))
+	if receiver.MaxBackoff > 0 && wait > float64(receiver.MaxBackoff) {
+		wait = float64(receiver.MaxBackoff)
+	}
+	if receiver.Jitter > 0 {
+		wait -= wait * receiver.Jitter * rand.Float64()
+	}
+	backoff := time.Duration(wait)
+	if retryAfter := retryAfter(err); retryAfter > backoff {
+		backoff = retryAfter
+	}
+
+	if receiver.MaxElapsed > 0 && elapsed+backoff > receiver.MaxElapsed {
+		return 0, false
+	}
+	return backoff, true
+}
+
+func retryAfter(err error) time.Duration {
+	var apiErr *googleapi.Error
+	if !errors.As(err, &apiErr) || apiErr.Header == nil {
+		return 0
+	}
+	value := apiErr.Header.Get("Retry-After")
+	if value == "" {
+		return 0
+	}
+	if seconds, parseErr := strconv.Atoi(value); parseErr == nil {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, parseErr := http.ParseTime(value); parseErr == nil {
+		return time.Until(date)
+	}
+	return 0
+}
+
+func (receiver *Options3k) retry(op string, ctx context.Context, call func() error) error {
+	policy := receiver.RetryPolicy
+	if policy == nil {
+		policy = DefaultRetryPolicy
+	}
+	started := time.Now()
+	for attempt := 1; ; attempt++ {
+		if ctx.Err() != nil {
+			return wrapError(op, ctx.Err())
+		}
+		err := wrapError(op, call())
+		if err == nil {
+			return nil
+		}
+		wait, ok := policy.Backoff(attempt, time.Since(started), err)
+		if !ok {
+			return err
+		}
+		receiver.logger().Printf("%s failed on attempt %d, retrying in %v: %s\n", op, attempt, wait.Round(time.Millisecond), err.Error())
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return wrapError(op, ctx.Err())
+		case <-timer.C:
+		}
+	}
+}
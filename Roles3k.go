@@ -0,0 +1,289 @@
+package googleadmin3k
+
+import (
+	"context"
+	"encoding/csv"
+	admin "google.golang.org/api/admin/directory/v1"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+/*Role assignment scopes*/
+const (
+	ScopeCustomer = "CUSTOMER"
+	ScopeOrgUnit  = "ORG_UNIT"
+)
+
+/*Roles and privileges methods*/
+
+// ListRoles returns the system roles along with the custom roles of the customer.
+func (receiver *Directory3k) ListRoles(ctx context.Context) ([]*admin.Role, error) {
+	roles := newPageIterator(func(pageToken string) ([]*admin.Role, string, error) {
+		request := receiver.Service.Roles.List(receiver.CustomerID).MaxResults(100).Context(ctx)
+		if pageToken != "" {
+			request.PageToken(pageToken)
+		}
+		var response *admin.Roles
+		err := receiver.retry("ListRoles", ctx, func() (err error) {
+			response, err = request.Do()
+			return err
+		})
+		if err != nil {
+			receiver.logger().Println(err.Error())
+			return nil, "", err
+		}
+		return response.Items, response.NextPageToken, nil
+	})
+	return collectPages(roles)
+}
+
+// ListPrivileges returns every privilege a role can hold, children flattened after their parent.
+func (receiver *Directory3k) ListPrivileges(ctx context.Context) ([]*admin.Privilege, error) {
+	var response *admin.Privileges
+	err := receiver.retry("ListPrivileges", ctx, func() (err error) {
+		response, err = receiver.Service.Privileges.List(receiver.CustomerID).Context(ctx).Do()
+		return err
+	})
+	if err != nil {
+		receiver.logger().Println(err.Error())
+		return nil, err
+	}
+	var privileges []*admin.Privilege
+	var flatten func([]*admin.Privilege)
+	flatten = func(items []*admin.Privilege) {
+		for _, privilege := range items {
+			privileges = append(privileges, privilege)
+			flatten(privilege.ChildPrivileges)
+		}
+	}
+	flatten(response.Items)
+	return privileges, nil
+}
+
+// CreateRole makes a custom role holding privilegeNames, as named by ListPrivileges.
+func (receiver *Directory3k) CreateRole(roleName, description string, privilegeNames []string, ctx context.Context) (*admin.Role, error) {
+	if roleName == "" {
+		return nil, invalidArgument("CreateRole", "role has no name")
+	}
+	privileges, err := receiver.ListPrivileges(ctx)
+	if err != nil {
+		return nil, err
+	}
+	serviceIDs := make(map[string]string)
+	for _, privilege := range privileges {
+		serviceIDs[privilege.PrivilegeName] = privilege.ServiceId
+	}
+	role := &admin.Role{RoleName: roleName, RoleDescription: description}
+	for _, name := range privilegeNames {
+		serviceID, ok := serviceIDs[name]
+		if !ok {
+			err = invalidArgument("CreateRole", "unknown privilege %q", name)
+			receiver.logger().Println(err.Error())
+			return nil, err
+		}
+		role.RolePrivileges = append(role.RolePrivileges, &admin.RoleRolePrivileges{PrivilegeName: name, ServiceId: serviceID})
+	}
+
+	var response *admin.Role
+	err = receiver.retry("CreateRole", ctx, func() (err error) {
+		response, err = receiver.Service.Roles.Insert(receiver.CustomerID, role).Context(ctx).Do()
+		return err
+	})
+	if err != nil {
+		receiver.logger().Println(err.Error())
+		return nil, err
+	}
+	receiver.logger().Printf("Role [%s] created with %d privileges\n", response.RoleName, len(response.RolePrivileges))
+	return response, nil
+}
+
+// DeleteRole fails while the role is still assigned.
+func (receiver *Directory3k) DeleteRole(roleID int64, ctx context.Context) error {
+	err := receiver.retry("DeleteRole", ctx, func() error {
+		return receiver.Service.Roles.Delete(receiver.CustomerID, strconv.FormatInt(roleID, 10)).Context(ctx).Do()
+	})
+	if err != nil {
+		receiver.logger().Println(err.Error())
+		return err
+	}
+	receiver.logger().Printf("Role [%d] deleted\n", roleID)
+	return nil
+}
+
+/*Role assignment methods*/
+
+// ListRoleAssignments returns the assignments held by userKey, or every assignment of the customer when userKey is empty.
+func (receiver *Directory3k) ListRoleAssignments(userKey string, ctx context.Context) ([]*admin.RoleAssignment, error) {
+	assignments := newPageIterator(func(pageToken string) ([]*admin.RoleAssignment, string, error) {
+		request := receiver.Service.RoleAssignments.List(receiver.CustomerID).MaxResults(200).Context(ctx)
+		if userKey != "" {
+			request.UserKey(userKey)
+		}
+		if pageToken != "" {
+			request.PageToken(pageToken)
+		}
+		var response *admin.RoleAssignments
+		err := receiver.retry("ListRoleAssignments", ctx, func() (err error) {
+			response, err = request.Do()
+			return err
+		})
+		if err != nil {
+			receiver.logger().Println(err.Error())
+			return nil, "", err
+		}
+		return response.Items, response.NextPageToken, nil
+	})
+	return collectPages(assignments)
+}
+
+// AssignRole gives the role to userKey across the customer, or only within orgUnitPath when it is not empty.
+func (receiver *Directory3k) AssignRole(userKey string, roleID int64, orgUnitPath string, ctx context.Context) (*admin.RoleAssignment, error) {
+	user, err := receiver.GetUser(userKey, ctx, "id")
+	if err != nil {
+		return nil, err
+	}
+	assignment := &admin.RoleAssignment{AssignedTo: user.Id, RoleId: roleID, ScopeType: ScopeCustomer}
+	if orgUnitPath != "" && orgUnitPath != "/" {
+		orgUnit, err := receiver.GetOrgUnit(orgUnitPath, ctx, "orgUnitId")
+		if err != nil {
+			return nil, err
+		}
+		assignment.ScopeType = ScopeOrgUnit
+		assignment.OrgUnitId = strings.TrimPrefix(orgUnit.OrgUnitId, "id:")
+	}
+
+	var response *admin.RoleAssignment
+	err = receiver.retry("AssignRole", ctx, func() (err error) {
+		response, err = receiver.Service.RoleAssignments.Insert(receiver.CustomerID, assignment).Context(ctx).Do()
+		return err
+	})
+	if err != nil {
+		receiver.logger().Println(err.Error())
+		return nil, err
+	}
+	receiver.logger().Printf("Role [%d] assigned to (%s) with scope %s\n", roleID, userKey, assignment.ScopeType)
+	return response, nil
+}
+
+func (receiver *Directory3k) UnassignRole(roleAssignmentID int64, ctx context.Context) error {
+	err := receiver.retry("UnassignRole", ctx, func() error {
+		return receiver.Service.RoleAssignments.Delete(receiver.CustomerID, strconv.FormatInt(roleAssignmentID, 10)).Context(ctx).Do()
+	})
+	if err != nil {
+		receiver.logger().Println(err.Error())
+		return err
+	}
+	receiver.logger().Printf("Role assignment [%d] removed\n", roleAssignmentID)
+	return nil
+}
+
+/*Delegated admin report*/
+type DelegatedAdmin struct {
+	ID string
+	// Email is empty when the assignee is not a user, such as a group or a deleted account.
+	Email  string
+	Grants []*AdminGrant
+}
+
+type AdminGrant struct {
+	AssignmentID int64
+	RoleID       int64
+	RoleName     string
+	SuperAdmin   bool
+	ScopeType    string
+	// OrgUnitPath is set for grants scoped to an org unit.
+	OrgUnitPath string
+	Privileges  []string
+}
+
+type AdminReport struct {
+	Admins []*DelegatedAdmin
+}
+
+// DelegatedAdminReport lists everyone holding an admin role, what each role allows and where.
+func (receiver *Directory3k) DelegatedAdminReport(maxRoutines int, ctx context.Context) (*AdminReport, error) {
+	roles, err := receiver.ListRoles(ctx)
+	if err != nil {
+		return nil, err
+	}
+	rolesByID := make(map[int64]*admin.Role)
+	for _, role := range roles {
+		rolesByID[role.RoleId] = role
+	}
+	orgUnits, err := receiver.ListOrgUnits(ctx, "orgUnitId", "orgUnitPath")
+	if err != nil {
+		return nil, err
+	}
+	orgUnitPaths := make(map[string]string)
+	for _, orgUnit := range orgUnits {
+		orgUnitPaths[strings.TrimPrefix(orgUnit.OrgUnitId, "id:")] = orgUnit.OrgUnitPath
+	}
+	assignments, err := receiver.ListRoleAssignments("", ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &AdminReport{}
+	adminsByID := make(map[string]*DelegatedAdmin)
+	for _, assignment := range assignments {
+		delegated, ok := adminsByID[assignment.AssignedTo]
+		if !ok {
+			delegated = &DelegatedAdmin{ID: assignment.AssignedTo}
+			adminsByID[assignment.AssignedTo] = delegated
+			report.Admins = append(report.Admins, delegated)
+		}
+		grant := &AdminGrant{
+			AssignmentID: assignment.RoleAssignmentId,
+			RoleID:       assignment.RoleId,
+			ScopeType:    assignment.ScopeType,
+			OrgUnitPath:  orgUnitPaths[assignment.OrgUnitId],
+		}
+		if role, ok := rolesByID[assignment.RoleId]; ok {
+			grant.RoleName, grant.SuperAdmin = role.RoleName, role.IsSuperAdminRole
+			for _, privilege := range role.RolePrivileges {
+				grant.Privileges = append(grant.Privileges, privilege.PrivilegeName)
+			}
+		}
+		delegated.Grants = append(delegated.Grants, grant)
+	}
+
+	results := runPool(report.Admins, func(delegated *DelegatedAdmin) string { return delegated.ID }, maxRoutines, ErrNotFound, ctx, func(delegated *DelegatedAdmin) error {
+		user, err := receiver.GetUser(delegated.ID, ctx, "primaryEmail")
+		if err != nil {
+			return err
+		}
+		delegated.Email = user.PrimaryEmail
+		return nil
+	})
+	sort.Slice(report.Admins, func(i, j int) bool {
+		if report.Admins[i].Email != report.Admins[j].Email {
+			return report.Admins[i].Email < report.Admins[j].Email
+		}
+		return report.Admins[i].ID < report.Admins[j].ID
+	})
+	receiver.logger().Printf("Admin report: %d admins holding %d role assignments\n", len(report.Admins), len(assignments))
+	return report, firstBulkError(results)
+}
+
+// CSV writes one row per grant.
+func (receiver *AdminReport) CSV(writer io.Writer) error {
+	csvWriter := csv.NewWriter(writer)
+	csvWriter.Write([]string{"email", "id", "role", "super_admin", "scope", "org_unit", "privileges"})
+	for _, delegated := range receiver.Admins {
+		for _, grant := range delegated.Grants {
+			csvWriter.Write([]string{
+				delegated.Email,
+				delegated.ID,
+				grant.RoleName,
+				strconv.FormatBool(grant.SuperAdmin),
+				grant.ScopeType,
+				grant.OrgUnitPath,
+				strings.Join(grant.Privileges, " "),
+			})
+		}
+	}
+	csvWriter.Flush()
+	return csvWriter.Error()
+}
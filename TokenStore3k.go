@@ -0,0 +1,293 @@
+package googleadmin3k
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+	"io/fs"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// TokenStore persists the OAuth2 token used by the TokenStore builders. Save is called
+// with every refreshed token so the latest refresh token is never lost.
+type TokenStore interface {
+	Load() (*oauth2.Token, error)
+	Save(token *oauth2.Token) error
+}
+
+/*In-memory store*/
+type MemoryTokenStore struct {
+	mutex sync.Mutex
+	token *oauth2.Token
+}
+
+func NewMemoryTokenStore(token *oauth2.Token) *MemoryTokenStore {
+	return &MemoryTokenStore{token: token}
+}
+
+func (receiver *MemoryTokenStore) Load() (*oauth2.Token, error) {
+	receiver.mutex.Lock()
+	defer receiver.mutex.Unlock()
+	if receiver.token == nil {
+		return nil, &Error3k{Op: "MemoryTokenStore.Load", Kind: ErrNotFound, Err: errors.New("no token stored")}
+	}
+	token := *receiver.token
+	return &token, nil
+}
+
+func (receiver *MemoryTokenStore) Save(token *oauth2.Token) error {
+	receiver.mutex.Lock()
+	defer receiver.mutex.Unlock()
+	saved := *token
+	receiver.token = &saved
+	return nil
+}
+
+/*Plain JSON file store*/
+type FileTokenStore struct {
+	Path  string
+	mutex sync.Mutex
+}
+
+func NewFileTokenStore(path string) *FileTokenStore {
+	return &FileTokenStore{Path: path}
+}
+
+func (receiver *FileTokenStore) Load() (*oauth2.Token, error) {
+	receiver.mutex.Lock()
+	defer receiver.mutex.Unlock()
+	data, err := readTokenFile("FileTokenStore.Load", receiver.Path)
+	if err != nil {
+		return nil, err
+	}
+	token := &oauth2.Token{}
+	if err = json.Unmarshal(data, token); err != nil {
+		return nil, wrapError("FileTokenStore.Load", err)
+	}
+	return token, nil
+}
+
+func (receiver *FileTokenStore) Save(token *oauth2.Token) error {
+	receiver.mutex.Lock()
+	defer receiver.mutex.Unlock()
+	data, err := json.Marshal(token)
+	if err != nil {
+		return wrapError("FileTokenStore.Save", err)
+	}
+	return writeTokenFile("FileTokenStore.Save", receiver.Path, data)
+}
+
+/*AES-GCM encrypted file store*/
+type EncryptedFileTokenStore struct {
+	Path  string
+	aead  cipher.AEAD
+	mutex sync.Mutex
+}
+
+// NewEncryptedFileTokenStore takes a 16, 24 or 32 byte AES key.
+func NewEncryptedFileTokenStore(path string, key []byte) (*EncryptedFileTokenStore, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, invalidArgument("NewEncryptedFileTokenStore", "%v", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, wrapError("NewEncryptedFileTokenStore", err)
+	}
+	return &EncryptedFileTokenStore{Path: path, aead: aead}, nil
+}
+
+func (receiver *EncryptedFileTokenStore) Load() (*oauth2.Token, error) {
+	receiver.mutex.Lock()
+	defer receiver.mutex.Unlock()
+	data, err := readTokenFile("EncryptedFileTokenStore.Load", receiver.Path)
+	if err != nil {
+		return nil, err
+	}
+	nonceSize := receiver.aead.NonceSize()
+	if len(data) < nonceSize {
+		return nil, invalidArgument("EncryptedFileTokenStore.Load", "%s is too short to be an encrypted token", receiver.Path)
+	}
+	plaintext, err := receiver.aead.Open(nil, data[:nonceSize], data[nonceSize:], nil)
+	if err != nil {
+		return nil, invalidArgument("EncryptedFileTokenStore.Load", "decrypting %s: %v", receiver.Path, err)
+	}
+	token := &oauth2.Token{}
+	if err = json.Unmarshal(plaintext, token); err != nil {
+		return nil, wrapError("EncryptedFileTokenStore.Load", err)
+	}
+	return token, nil
+}
+
+func (receiver *EncryptedFileTokenStore) Save(token *oauth2.Token) error {
+	receiver.mutex.Lock()
+	defer receiver.mutex.Unlock()
+	plaintext, err := json.Marshal(token)
+	if err != nil {
+		return wrapError("EncryptedFileTokenStore.Save", err)
+	}
+	nonce := make([]byte, receiver.aead.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		return wrapError("EncryptedFileTokenStore.Save", err)
+	}
+	return writeTokenFile("EncryptedFileTokenStore.Save", receiver.Path, receiver.aead.Seal(nonce, nonce, plaintext, nil))
+}
+
+func readTokenFile(op, path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, &Error3k{Op: op, Kind: ErrNotFound, Err: err}
+	}
+	if err != nil {
+		return nil, wrapError(op, err)
+	}
+	return data, nil
+}
+
+func writeTokenFile(op, path string, data []byte) error {
+	temp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return wrapError(op, err)
+	}
+	defer os.Remove(temp.Name())
+	if _, err = temp.Write(data); err != nil {
+		temp.Close()
+		return wrapError(op, err)
+	}
+	if err = temp.Close(); err != nil {
+		return wrapError(op, err)
+	}
+	if err = os.Chmod(temp.Name(), 0600); err != nil {
+		return wrapError(op, err)
+	}
+	return wrapError(op, os.Rename(temp.Name(), path))
+}
+
+/*Clients*/
+
+// storingTokenSource writes every newly minted token back to its TokenStore.
+type storingTokenSource struct {
+	source oauth2.TokenSource
+	store  TokenStore
+	mutex  sync.Mutex
+	last   string
+}
+
+func (receiver *storingTokenSource) Token() (*oauth2.Token, error) {
+	token, err := receiver.source.Token()
+	if err != nil {
+		return nil, err
+	}
+	receiver.mutex.Lock()
+	defer receiver.mutex.Unlock()
+	if token.AccessToken != receiver.last {
+		if err = receiver.store.Save(token); err != nil {
+			log.Printf("Saving refreshed token failed: %s\n", err.Error())
+		} else {
+			receiver.last = token.AccessToken
+		}
+	}
+	return token, nil
+}
+
+// TokenStoreClient builds an installed-app client whose refreshed tokens are saved back to store.
+func TokenStoreClient(clientSecret []byte, scopes []string, store TokenStore) (*http.Client, error) {
+	config, err := google.ConfigFromJSON(clientSecret, scopes...)
+	if err != nil {
+		log.Println(err.Error())
+		return nil, wrapError("TokenStoreClient", err)
+	}
+	token, err := store.Load()
+	if err != nil {
+		log.Println(err.Error())
+		return nil, wrapError("TokenStoreClient", err)
+	}
+	source := &storingTokenSource{
+		source: config.TokenSource(context.Background(), token),
+		store:  store,
+		last:   token.AccessToken,
+	}
+	return oauth2.NewClient(context.Background(), source), nil
+}
+
+// AuthorizeLoopback runs the installed-app authorization flow against a local loopback
+// redirect, saves the resulting token to store and returns it.
+func AuthorizeLoopback(clientSecret []byte, scopes []string, store TokenStore, ctx context.Context) (*oauth2.Token, error) {
+	config, err := google.ConfigFromJSON(clientSecret, scopes...)
+	if err != nil {
+		log.Println(err.Error())
+		return nil, wrapError("AuthorizeLoopback", err)
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, wrapError("AuthorizeLoopback", err)
+	}
+	config.RedirectURL = fmt.Sprintf("http://%s/", listener.Addr().String())
+
+	stateBytes := make([]byte, 16)
+	if _, err = rand.Read(stateBytes); err != nil {
+		listener.Close()
+		return nil, wrapError("AuthorizeLoopback", err)
+	}
+	state := hex.EncodeToString(stateBytes)
+
+	codes := make(chan string, 1)
+	failures := make(chan error, 1)
+	server := &http.Server{Handler: http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		query := request.URL.Query()
+		if query.Get("state") != state {
+			http.Error(writer, "State mismatch", http.StatusBadRequest)
+			return
+		}
+		if message := query.Get("error"); message != "" {
+			http.Error(writer, "Authorization failed: "+message, http.StatusBadRequest)
+			select {
+			case failures <- fmt.Errorf("authorization denied: %s", message):
+			default:
+			}
+			return
+		}
+		fmt.Fprintln(writer, "Authorization complete, you may close this window.")
+		select {
+		case codes <- query.Get("code"):
+		default:
+		}
+	})}
+	go server.Serve(listener)
+	defer server.Close()
+
+	authURL := config.AuthCodeURL(state, oauth2.AccessTypeOffline, oauth2.ApprovalForce)
+	log.Printf("Open the following URL in your browser to authorize access:\n%s\n", authURL)
+
+	var code string
+	select {
+	case code = <-codes:
+	case err = <-failures:
+		return nil, &Error3k{Op: "AuthorizeLoopback", Kind: ErrPermissionDenied, Err: err}
+	case <-ctx.Done():
+		return nil, wrapError("AuthorizeLoopback", ctx.Err())
+	}
+
+	token, err := config.Exchange(ctx, code)
+	if err != nil {
+		log.Println(err.Error())
+		return nil, wrapError("AuthorizeLoopback", err)
+	}
+	if err = store.Save(token); err != nil {
+		return nil, err
+	}
+	log.Println("Authorization token saved")
+	return token, nil
+}
@@ -0,0 +1,248 @@
+package googleadmin3k
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha1"
+	"encoding/hex"
+	admin "google.golang.org/api/admin/directory/v1"
+	"google.golang.org/api/googleapi"
+	"strings"
+)
+
+/*Password hash functions accepted by the Directory API*/
+const (
+	HashFunctionSHA1  = "SHA-1"
+	HashFunctionMD5   = "MD5"
+	HashFunctionCrypt = "crypt"
+)
+
+// SetUserPassword sets the password on user before it is created or updated. SHA-1 and MD5 hash a plain
+// password locally so it never leaves the process in clear text; crypt expects a value already hashed
+// with crypt(3) ($1$, $5$ or $6$). An empty hashFunction sends the plain password for Google to hash.
+func SetUserPassword(user *admin.User, password, hashFunction string, changePasswordAtNextLogin bool) error {
+	switch hashFunction {
+	case "", HashFunctionSHA1, HashFunctionMD5:
+		if len(password) < 8 || len(password) > 100 {
+			return invalidArgument("SetUserPassword", "password must be between 8 and 100 characters")
+		}
+	case HashFunctionCrypt:
+		if !strings.HasPrefix(password, "$1$") && !strings.HasPrefix(password, "$5$") && !strings.HasPrefix(password, "$6$") {
+			return invalidArgument("SetUserPassword", "crypt passwords must already be hashed with a $1$, $5$ or $6$ prefix")
+		}
+	default:
+		return invalidArgument("SetUserPassword", "unsupported hash function %q", hashFunction)
+	}
+
+	switch hashFunction {
+	case HashFunctionSHA1:
+		sum := sha1.Sum([]byte(password))
+		password = hex.EncodeToString(sum[:])
+	case HashFunctionMD5:
+		sum := md5.Sum([]byte(password))
+		password = hex.EncodeToString(sum[:])
+	}
+	user.Password = password
+	user.HashFunction = hashFunction
+	user.ChangePasswordAtNextLogin = changePasswordAtNextLogin
+	if !changePasswordAtNextLogin {
+		user.ForceSendFields = append(user.ForceSendFields, "ChangePasswordAtNextLogin")
+	}
+	return nil
+}
+
+func validateUser(op string, user *admin.User, creating bool) error {
+	if user == nil {
+		return invalidArgument(op, "user is nil")
+	}
+	if creating || user.PrimaryEmail != "" {
+		if _, err := domainFromEmail(op, user.PrimaryEmail); err != nil {
+			return err
+		}
+	}
+	if creating {
+		if user.Name == nil || user.Name.GivenName == "" || user.Name.FamilyName == "" {
+			return invalidArgument(op, "%s needs a given and family name", user.PrimaryEmail)
+		}
+		if user.Password == "" {
+			return invalidArgument(op, "%s needs a password", user.PrimaryEmail)
+		}
+	}
+	if user.OrgUnitPath != "" && !strings.HasPrefix(user.OrgUnitPath, "/") {
+		return invalidArgument(op, "org unit path %q must start with /", user.OrgUnitPath)
+	}
+	return nil
+}
+
+/*User lifecycle methods*/
+func (receiver *Directory3k) GetUser(userKey string, ctx context.Context, fields ...googleapi.Field) (*admin.User, error) {
+	var response *admin.User
+	err := receiver.retry("GetUser", ctx, func() (err error) {
+		response, err = receiver.Service.Users.Get(userKey).Fields(itemFields(fields)).Context(ctx).Do()
+		return err
+	})
+	if err != nil {
+		receiver.logger().Println(err.Error())
+		return nil, err
+	}
+	return response, nil
+}
+
+func (receiver *Directory3k) CreateUser(user *admin.User, ctx context.Context) (*admin.User, error) {
+	if err := validateUser("CreateUser", user, true); err != nil {
+		receiver.logger().Println(err.Error())
+		return nil, err
+	}
+	var response *admin.User
+	err := receiver.retry("CreateUser", ctx, func() (err error) {
+		response, err = receiver.Service.Users.Insert(user).Context(ctx).Do()
+		return err
+	})
+	if err != nil {
+		receiver.logger().Println(err.Error())
+		return nil, err
+	}
+	receiver.logger().Printf("User [%s] created in (%s)\n", response.PrimaryEmail, response.OrgUnitPath)
+	return response, nil
+}
+
+// UpdateUser replaces the user resource, fields left empty are cleared. Use PatchUser to change only some fields.
+func (receiver *Directory3k) UpdateUser(userKey string, user *admin.User, ctx context.Context) (*admin.User, error) {
+	if err := validateUser("UpdateUser", user, false); err != nil {
+		receiver.logger().Println(err.Error())
+		return nil, err
+	}
+	var response *admin.User
+	err := receiver.retry("UpdateUser", ctx, func() (err error) {
+		response, err = receiver.Service.Users.Update(userKey, user).Context(ctx).Do()
+		return err
+	})
+	if err != nil {
+		receiver.logger().Println(err.Error())
+		return nil, err
+	}
+	receiver.logger().Printf("User [%s] updated\n", userKey)
+	return response, nil
+}
+
+func (receiver *Directory3k) PatchUser(userKey string, user *admin.User, ctx context.Context) (*admin.User, error) {
+	if err := validateUser("PatchUser", user, false); err != nil {
+		receiver.logger().Println(err.Error())
+		return nil, err
+	}
+	var response *admin.User
+	err := receiver.retry("PatchUser", ctx, func() (err error) {
+		response, err = receiver.Service.Users.Patch(userKey, user).Context(ctx).Do()
+		return err
+	})
+	if err != nil {
+		receiver.logger().Println(err.Error())
+		return nil, err
+	}
+	receiver.logger().Printf("User [%s] patched\n", userKey)
+	return response, nil
+}
+
+func (receiver *Directory3k) SuspendUser(userKey string, ctx context.Context) (*admin.User, error) {
+	return receiver.PatchUser(userKey, &admin.User{Suspended: true}, ctx)
+}
+
+func (receiver *Directory3k) UnsuspendUser(userKey string, ctx context.Context) (*admin.User, error) {
+	return receiver.PatchUser(userKey, &admin.User{Suspended: false, ForceSendFields: []string{"Suspended"}}, ctx)
+}
+
+// MoveUser places the user in the org unit at orgUnitPath.
+func (receiver *Directory3k) MoveUser(userKey, orgUnitPath string, ctx context.Context) (*admin.User, error) {
+	return receiver.PatchUser(userKey, &admin.User{OrgUnitPath: orgUnitPath}, ctx)
+}
+
+// RenameUser changes the primary email, Google keeps the old address as an alias.
+func (receiver *Directory3k) RenameUser(userKey, newPrimaryEmail string, ctx context.Context) (*admin.User, error) {
+	return receiver.PatchUser(userKey, &admin.User{PrimaryEmail: newPrimaryEmail}, ctx)
+}
+
+func (receiver *Directory3k) DeleteUser(userKey string, ctx context.Context) error {
+	err := receiver.retry("DeleteUser", ctx, func() error {
+		return receiver.Service.Users.Delete(userKey).Context(ctx).Do()
+	})
+	if err != nil {
+		receiver.logger().Println(err.Error())
+		return err
+	}
+	receiver.logger().Printf("User [%s] deleted\n", userKey)
+	return nil
+}
+
+// UndeleteUser restores a user deleted within the last 20 days. userID must be the unique ID,
+// an empty orgUnitPath restores into the root org unit.
+func (receiver *Directory3k) UndeleteUser(userID, orgUnitPath string, ctx context.Context) error {
+	if orgUnitPath == "" {
+		orgUnitPath = "/"
+	}
+	err := receiver.retry("UndeleteUser", ctx, func() error {
+		return receiver.Service.Users.Undelete(userID, &admin.UserUndelete{OrgUnitPath: orgUnitPath}).Context(ctx).Do()
+	})
+	if err != nil {
+		receiver.logger().Println(err.Error())
+		return err
+	}
+	receiver.logger().Printf("User [%s] restored into (%s)\n", userID, orgUnitPath)
+	return nil
+}
+
+func (receiver *Directory3k) MakeAdmin(userKey string, status bool, ctx context.Context) error {
+	makeAdmin := &admin.UserMakeAdmin{Status: status, ForceSendFields: []string{"Status"}}
+	err := receiver.retry("MakeAdmin", ctx, func() error {
+		return receiver.Service.Users.MakeAdmin(userKey, makeAdmin).Context(ctx).Do()
+	})
+	if err != nil {
+		receiver.logger().Println(err.Error())
+		return err
+	}
+	receiver.logger().Printf("User [%s] super admin status set to %t\n", userKey, status)
+	return nil
+}
+
+/*User alias methods*/
+func (receiver *Directory3k) ListUserAliases(userKey string, ctx context.Context) ([]string, error) {
+	var response *admin.Aliases
+	err := receiver.retry("ListUserAliases", ctx, func() (err error) {
+		response, err = receiver.Service.Users.Aliases.List(userKey).Context(ctx).Do()
+		return err
+	})
+	if err != nil {
+		receiver.logger().Println(err.Error())
+		return nil, err
+	}
+	return aliasNames("ListUserAliases", response)
+}
+
+func (receiver *Directory3k) InsertUserAlias(userKey, alias string, ctx context.Context) (*admin.Alias, error) {
+	if _, err := domainFromEmail("InsertUserAlias", alias); err != nil {
+		receiver.logger().Println(err.Error())
+		return nil, err
+	}
+	var response *admin.Alias
+	err := receiver.retry("InsertUserAlias", ctx, func() (err error) {
+		response, err = receiver.Service.Users.Aliases.Insert(userKey, &admin.Alias{Alias: alias}).Context(ctx).Do()
+		return err
+	})
+	if err != nil {
+		receiver.logger().Println(err.Error())
+		return nil, err
+	}
+	receiver.logger().Printf("Alias [%s] added to user (%s)\n", alias, userKey)
+	return response, nil
+}
+
+func (receiver *Directory3k) DeleteUserAlias(userKey, alias string, ctx context.Context) error {
+	err := receiver.retry("DeleteUserAlias", ctx, func() error {
+		return receiver.Service.Users.Aliases.Delete(userKey, alias).Context(ctx).Do()
+	})
+	if err != nil {
+		receiver.logger().Println(err.Error())
+		return err
+	}
+	receiver.logger().Printf("Alias [%s] removed from user (%s)\n", alias, userKey)
+	return nil
+}
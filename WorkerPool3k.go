@@ -0,0 +1,80 @@
+package googleadmin3k
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+/*Bulk item outcomes*/
+const (
+	BulkSucceeded = "SUCCEEDED"
+	BulkFailed    = "FAILED"
+	BulkSkipped   = "SKIPPED"
+)
+
+type BulkResult struct {
+	Key    string
+	Status string
+	Err    error
+}
+
+func countBulkResults(results []*BulkResult, status string) int {
+	count := 0
+	for _, result := range results {
+		if result.Status == status {
+			count++
+		}
+	}
+	return count
+}
+
+// firstBulkError is the error of the first failed item, nil when every item succeeded or was skipped.
+func firstBulkError(results []*BulkResult) error {
+	for _, result := range results {
+		if result.Status == BulkFailed {
+			return result.Err
+		}
+	}
+	return nil
+}
+
+// runPool calls work on every item from a fixed set of workers, so one slow call never stalls the rest.
+// Results keep the order of items. Errors matching skipKind are reported as skipped, and items not yet
+// started when ctx is cancelled fail with the context's error.
+func runPool[T any](items []T, key func(T) string, workers int, skipKind error, ctx context.Context, work func(T) error) []*BulkResult {
+	results := make([]*BulkResult, len(items))
+	if workers < 1 {
+		workers = 1
+	}
+	if workers > len(items) {
+		workers = len(items)
+	}
+
+	indexes := make(chan int)
+	wg := &sync.WaitGroup{}
+	wg.Add(workers)
+	for worker := 0; worker < workers; worker++ {
+		go func() {
+			defer wg.Done()
+			for index := range indexes {
+				result := &BulkResult{Key: key(items[index]), Status: BulkSucceeded}
+				if err := ctx.Err(); err != nil {
+					result.Status, result.Err = BulkFailed, wrapError("runPool", err)
+				} else if err = work(items[index]); err != nil {
+					result.Status, result.Err = BulkFailed, err
+					if skipKind != nil && errors.Is(err, skipKind) {
+						result.Status = BulkSkipped
+					}
+				}
+				results[index] = result
+			}
+		}()
+	}
+	for index := range items {
+		indexes <- index
+	}
+	close(indexes)
+	wg.Wait()
+	return results
+}
@@ -0,0 +1,206 @@
+package googleadmin3k
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	admin "google.golang.org/api/admin/directory/v1"
+	"google.golang.org/api/option"
+)
+
+// newTestDirectory returns a Directory3k whose calls go to handler, without retries or log output.
+func newTestDirectory(t *testing.T, handler http.HandlerFunc) *Directory3k {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	service, err := admin.NewService(context.Background(), option.WithEndpoint(server.URL+"/"), option.WithHTTPClient(server.Client()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	directory := &Directory3k{Service: service, Client: server.Client(), CustomerID: "C123", Domain: "example.com"}
+	directory.RetryPolicy = NoRetry
+	directory.Logger = log.New(io.Discard, "", 0)
+	return directory
+}
+
+func writeAPIError(writer http.ResponseWriter, code int, reason string) {
+	writer.Header().Set("Content-Type", "application/json")
+	writer.WriteHeader(code)
+	fmt.Fprintf(writer, `{"error":{"code":%d,"message":"%s","errors":[{"reason":"%s"}]}}`, code, reason, reason)
+}
+
+// testOutcome picks the response for an address from its local part.
+func testOutcome(email string) string {
+	switch {
+	case strings.HasPrefix(email, "dup"):
+		return BulkSkipped
+	case strings.HasPrefix(email, "fail"):
+		return BulkFailed
+	}
+	return BulkSucceeded
+}
+
+func testAddresses(count int) []string {
+	prefixes := []string{"ok", "dup", "fail"}
+	addresses := make([]string, count)
+	for i := range addresses {
+		addresses[i] = fmt.Sprintf("%s%d@example.com", prefixes[i%len(prefixes)], i)
+	}
+	return addresses
+}
+
+func TestInsertMembers(t *testing.T) {
+	directory := newTestDirectory(t, func(writer http.ResponseWriter, request *http.Request) {
+		member := &admin.Member{}
+		if err := json.NewDecoder(request.Body).Decode(member); err != nil {
+			writeAPIError(writer, 400, "invalid")
+			return
+		}
+		switch testOutcome(member.Email) {
+		case BulkSkipped:
+			writeAPIError(writer, 409, "duplicate")
+		case BulkFailed:
+			writeAPIError(writer, 503, "backendError")
+		default:
+			json.NewEncoder(writer).Encode(member)
+		}
+	})
+
+	addresses := testAddresses(60)
+	members := make([]*admin.Member, len(addresses))
+	for i, address := range addresses {
+		members[i] = &admin.Member{Email: address, Role: "MEMBER"}
+	}
+	results, err := directory.InsertMembers(members, "group@example.com", 8, context.Background())
+	if !errors.Is(err, ErrTransient) {
+		t.Errorf("InsertMembers error = %v, want ErrTransient", err)
+	}
+	assertBulkResults(t, addresses, results, ErrDuplicate)
+}
+
+// writeTestGroup answers the group lookup deletes make before they start.
+func writeTestGroup(writer http.ResponseWriter) {
+	json.NewEncoder(writer).Encode(&admin.Group{Id: "G123", Email: "group@example.com"})
+}
+
+func TestDeleteMembers(t *testing.T) {
+	directory := newTestDirectory(t, func(writer http.ResponseWriter, request *http.Request) {
+		if request.Method == http.MethodGet {
+			writeTestGroup(writer)
+			return
+		}
+		if request.Method != http.MethodDelete {
+			writeAPIError(writer, 400, "invalid")
+			return
+		}
+		switch testOutcome(request.URL.Path[strings.LastIndex(request.URL.Path, "/")+1:]) {
+		case BulkSkipped:
+			writeAPIError(writer, 404, "notFound")
+		case BulkFailed:
+			writeAPIError(writer, 500, "backendError")
+		default:
+			writer.WriteHeader(http.StatusNoContent)
+		}
+	})
+
+	addresses := testAddresses(60)
+	results, err := directory.DeleteMembers(addresses, "group@example.com", 8, context.Background())
+	if !errors.Is(err, ErrTransient) {
+		t.Errorf("DeleteMembers error = %v, want ErrTransient", err)
+	}
+	assertBulkResults(t, addresses, results, ErrNotFound)
+}
+
+// A missing group answers 404 for every member, which must fail the call rather than skip each member.
+func TestDeleteMembersMissingGroup(t *testing.T) {
+	var deletes int32
+	directory := newTestDirectory(t, func(writer http.ResponseWriter, request *http.Request) {
+		if request.Method != http.MethodGet {
+			atomic.AddInt32(&deletes, 1)
+		}
+		writeAPIError(writer, 404, "notFound")
+	})
+
+	addresses := testAddresses(6)
+	results, err := directory.DeleteMembers(addresses, "missing@example.com", 4, context.Background())
+	if !errors.Is(err, ErrNotFound) || results != nil {
+		t.Errorf("DeleteMembers = %v, %v, want no results and ErrNotFound", results, err)
+	}
+	results, err = directory.BatchDeleteMembers("missing@example.com", addresses, context.Background())
+	if !errors.Is(err, ErrNotFound) || results != nil {
+		t.Errorf("BatchDeleteMembers = %v, %v, want no results and ErrNotFound", results, err)
+	}
+	if got := atomic.LoadInt32(&deletes); got != 0 {
+		t.Errorf("server got %d delete requests for a missing group", got)
+	}
+}
+
+func assertBulkResults(t *testing.T, addresses []string, results []*BulkResult, skipKind error) {
+	t.Helper()
+	if len(results) != len(addresses) {
+		t.Fatalf("got %d results for %d items", len(results), len(addresses))
+	}
+	for i, result := range results {
+		want := testOutcome(addresses[i])
+		if result.Key != addresses[i] || result.Status != want {
+			t.Errorf("result %d = %s %s, want %s %s", i, result.Key, result.Status, addresses[i], want)
+		}
+		switch want {
+		case BulkSucceeded:
+			if result.Err != nil {
+				t.Errorf("%s: unexpected error %v", result.Key, result.Err)
+			}
+		case BulkSkipped:
+			if !errors.Is(result.Err, skipKind) {
+				t.Errorf("%s: error %v, want %v", result.Key, result.Err, skipKind)
+			}
+		case BulkFailed:
+			if !errors.Is(result.Err, ErrTransient) {
+				t.Errorf("%s: error %v, want ErrTransient", result.Key, result.Err)
+			}
+		}
+	}
+}
+
+func TestRunPoolCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var requests int32
+	directory := newTestDirectory(t, func(writer http.ResponseWriter, request *http.Request) {
+		if request.Method == http.MethodGet {
+			writeTestGroup(writer)
+			return
+		}
+		atomic.AddInt32(&requests, 1)
+		cancel()
+		writer.WriteHeader(http.StatusNoContent)
+	})
+
+	const workers = 2
+	addresses := make([]string, 20)
+	for i := range addresses {
+		addresses[i] = fmt.Sprintf("ok%d@example.com", i)
+	}
+	results, err := directory.DeleteMembers(addresses, "group@example.com", workers, ctx)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("DeleteMembers error = %v, want context.Canceled", err)
+	}
+	if got := atomic.LoadInt32(&requests); got > workers {
+		t.Errorf("server got %d requests after cancel, want at most %d", got, workers)
+	}
+	// Each worker holds at most one item when the first response cancels ctx, the rest never start.
+	for _, result := range results[workers:] {
+		if result.Status != BulkFailed || !errors.Is(result.Err, context.Canceled) {
+			t.Errorf("%s: %s %v, want FAILED with context.Canceled", result.Key, result.Status, result.Err)
+		}
+	}
+}
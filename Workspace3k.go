@@ -0,0 +1,185 @@
+package googleadmin3k
+
+import (
+	"context"
+	"errors"
+	admin "google.golang.org/api/admin/directory/v1"
+	"google.golang.org/api/groupssettings/v1"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Options3k holds the settings shared by every service wrapper. Workspace3k copies its
+// Options3k into each service it builds.
+type Options3k struct {
+	RetryPolicy RetryPolicy
+	Logger      *log.Logger
+}
+
+func (receiver *Options3k) logger() *log.Logger {
+	if receiver.Logger == nil {
+		return log.Default()
+	}
+	return receiver.Logger
+}
+
+/*Initializer*/
+type Workspace3k struct {
+	Options3k
+	Client     *http.Client
+	AdminEmail string
+	Domain     string
+	CustomerID string
+	// RateLimits are shared by every goroutine using this Workspace3k, set them before building services.
+	RateLimits ServiceRateLimits
+
+	mutex           sync.Mutex
+	directory       *Directory3k
+	licensing       *Licensing3k
+	groupsMigration *GroupsMigration3k
+	groupSettings   *GroupSettings3k
+}
+
+// BuildWorkspace3k does not call any API; each service is built on first use.
+// Set CustomerID beforehand to skip its discovery through the Directory API.
+func BuildWorkspace3k(client *http.Client, adminEmail string) (*Workspace3k, error) {
+	domain, err := domainFromEmail("BuildWorkspace3k", adminEmail)
+	if err != nil {
+		log.Println(err.Error())
+		return nil, err
+	}
+	return &Workspace3k{Client: client, AdminEmail: adminEmail, Domain: domain}, nil
+}
+
+func BuildWorkspace3kServiceAccount(adminEmail string, scopes []string, serviceAccountKey []byte) (*Workspace3k, error) {
+	client, err := ServiceAccountClient(serviceAccountKey, adminEmail, scopes)
+	if err != nil {
+		return nil, err
+	}
+	return BuildWorkspace3k(client, adminEmail)
+}
+
+func BuildWorkspace3kDefaultCredentials(adminEmail, serviceAccountEmail string, scopes []string, ctx context.Context) (*Workspace3k, error) {
+	client, err := DefaultCredentialsClient(serviceAccountEmail, adminEmail, scopes, ctx)
+	if err != nil {
+		return nil, err
+	}
+	return BuildWorkspace3k(client, adminEmail)
+}
+
+func BuildWorkspace3kTokenStore(adminEmail string, scopes []string, clientSecret []byte, store TokenStore) (*Workspace3k, error) {
+	client, err := TokenStoreClient(clientSecret, scopes, store)
+	if err != nil {
+		return nil, err
+	}
+	return BuildWorkspace3k(client, adminEmail)
+}
+
+/*Services*/
+func (receiver *Workspace3k) Directory(ctx context.Context) (*Directory3k, error) {
+	receiver.mutex.Lock()
+	defer receiver.mutex.Unlock()
+	return receiver.directoryLocked(ctx)
+}
+
+func (receiver *Workspace3k) directoryLocked(ctx context.Context) (*Directory3k, error) {
+	if receiver.directory != nil {
+		return receiver.directory, nil
+	}
+	directory, err := buildDirectory3k(RateLimitedClient(receiver.Client, receiver.RateLimits.Directory), receiver.AdminEmail, receiver.CustomerID, receiver.Options3k, ctx)
+	if err != nil {
+		return nil, err
+	}
+	receiver.directory = directory
+	receiver.CustomerID = directory.CustomerID
+	return directory, nil
+}
+
+func (receiver *Workspace3k) Licensing(ctx context.Context) (*Licensing3k, error) {
+	receiver.mutex.Lock()
+	defer receiver.mutex.Unlock()
+	if receiver.licensing != nil {
+		return receiver.licensing, nil
+	}
+	if receiver.CustomerID == "" {
+		if _, err := receiver.directoryLocked(ctx); err != nil {
+			return nil, err
+		}
+	}
+	licensing3k, err := buildLicensing3k(RateLimitedClient(receiver.Client, receiver.RateLimits.Licensing), receiver.AdminEmail, receiver.CustomerID, receiver.Options3k, ctx)
+	if err != nil {
+		return nil, err
+	}
+	receiver.licensing = licensing3k
+	return licensing3k, nil
+}
+
+// GroupsMigration carries the CustomerID only if it was already known or discovered by another service.
+func (receiver *Workspace3k) GroupsMigration(ctx context.Context) (*GroupsMigration3k, error) {
+	receiver.mutex.Lock()
+	defer receiver.mutex.Unlock()
+	if receiver.groupsMigration != nil {
+		return receiver.groupsMigration, nil
+	}
+	groupsMigration3k, err := buildGroupsMigration3k(RateLimitedClient(receiver.Client, receiver.RateLimits.GroupsMigration), receiver.AdminEmail, receiver.CustomerID, receiver.Options3k, ctx)
+	if err != nil {
+		return nil, err
+	}
+	receiver.groupsMigration = groupsMigration3k
+	return groupsMigration3k, nil
+}
+
+func (receiver *Workspace3k) GroupSettings(ctx context.Context) (*GroupSettings3k, error) {
+	receiver.mutex.Lock()
+	defer receiver.mutex.Unlock()
+	if receiver.groupSettings != nil {
+		return receiver.groupSettings, nil
+	}
+	groupSettings3k, err := buildGroupSettings3k(RateLimitedClient(receiver.Client, receiver.RateLimits.GroupSettings), receiver.AdminEmail, receiver.Options3k, ctx)
+	if err != nil {
+		return nil, err
+	}
+	receiver.groupSettings = groupSettings3k
+	return groupSettings3k, nil
+}
+
+/*Cross-service workflows*/
+
+// ProvisionGroup creates group and then applies settings to it. Settings of a new group can take a
+// few seconds to become reachable, so not found responses are retried for up to a minute.
+func (receiver *Workspace3k) ProvisionGroup(group *admin.Group, settings *groupssettings.Groups, ctx context.Context) (*admin.Group, *groupssettings.Groups, error) {
+	directory, err := receiver.Directory(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	groupSettings, err := receiver.GroupSettings(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	created, err := directory.CreateGroup(group, ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	if settings == nil {
+		return created, nil, nil
+	}
+
+	settingsService := *groupSettings
+	settingsService.RetryPolicy = &ExponentialBackoff{
+		MaxElapsed:     time.Minute,
+		InitialBackoff: 2 * time.Second,
+		MaxBackoff:     16 * time.Second,
+		Multiplier:     2,
+		Jitter:         0.5,
+		Retryable: func(err error) bool {
+			return errors.Is(err, ErrNotFound) || IsRetryable(err)
+		},
+	}
+	configured, err := settingsService.Patch(created.Email, settings, ctx)
+	if err != nil {
+		return created, nil, err
+	}
+	return created, configured, nil
+}
@@ -1,4 +1,4 @@
-module github.com/boom3k/googleadmin3k
+module github.com/boom3k/googleadmin3k/v2
 
 go 1.18
 